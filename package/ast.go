@@ -10,18 +10,45 @@ const (
 	SelectKind AstKind = iota
 	CreateTableKind
 	InsertKind
+	CreateIndexKind
+	DropTableKind
+	DropIndexKind
+	UpdateKind
+	DeleteKind
 )
 
 type Statement struct {
 	SelectStatement      *SelectStatement
 	CreateTableStatement *CreateTableStatement
 	InsertStatement      *InsertStatement
+	CreateIndexStatement *CreateIndexStatement
+	DropTableStatement   *DropTableStatement
+	DropIndexStatement   *DropIndexStatement
+	UpdateStatement      *UpdateStatement
+	DeleteStatement      *DeleteStatement
 	Kind                 AstKind
 }
 
 type InsertStatement struct {
-	table  Token
-	values *[]*expression
+	table   Token
+	columns *[]*Token
+	values  *[]*expression
+}
+
+type UpdateStatement struct {
+	table Token
+	set   *[]*updateAssignment
+	where *expression
+}
+
+type updateAssignment struct {
+	column Token
+	value  *expression
+}
+
+type DeleteStatement struct {
+	table Token
+	where *expression
 }
 
 type expressionKind uint
@@ -29,23 +56,55 @@ type expressionKind uint
 const (
 	literalKind expressionKind = iota
 	binaryKind
+	unaryKind
+	castKind
+	callKind
+	subqueryKind
 )
 
 type expression struct {
-	literal *Token
-	binary  *binaryExpression
-	kind    expressionKind
+	literal  *Token
+	binary   *binaryExpression
+	unary    *unaryExpression
+	cast     *castExpression
+	call     *callExpression
+	subquery *subqueryExpression
+	kind     expressionKind
 }
 
 type columnDefinition struct {
-	name       Token
-	datatype   Token
-	primaryKey bool
+	name         Token
+	datatype     Token
+	precision    *Token
+	scale        *Token
+	primaryKey   bool
+	notNull      bool
+	unique       bool
+	defaultValue *expression
 }
 
 type CreateTableStatement struct {
-	name Token
-	cols *[]*columnDefinition
+	name        Token
+	ifNotExists bool
+	cols        *[]*columnDefinition
+	using       *Token
+}
+
+type CreateIndexStatement struct {
+	name   Token
+	unique bool
+	table  Token
+	column Token
+}
+
+type DropTableStatement struct {
+	name     Token
+	ifExists bool
+}
+
+type DropIndexStatement struct {
+	name     Token
+	ifExists bool
 }
 
 type SelectItem struct {
@@ -54,10 +113,60 @@ type SelectItem struct {
 	As       *Token
 }
 
+type orderByItem struct {
+	exp        *expression
+	desc       bool
+	nullsFirst bool
+}
+
+type fromItemKind uint
+
+const (
+	tableFromKind fromItemKind = iota
+	joinFromKind
+	subqueryFromKind
+)
+
+// fromItem is what a query selects from: a table or the rows of a
+// subquery, which may be given a name with an alias, or two items joined
+// together
+type fromItem struct {
+	table    *Token
+	subquery *SelectStatement
+	alias    *Token
+	join     *joinExpression
+	kind     fromItemKind
+}
+
+type joinKind uint
+
+const (
+	innerJoin joinKind = iota
+	leftJoin
+	rightJoin
+	fullJoin
+)
+
+// joinExpression joins the rows of a and b matching on, or having the
+// same values in the using columns. Comma and CROSS joins are inner
+// joins with neither, they match every pair of rows.
+type joinExpression struct {
+	a     fromItem
+	b     fromItem
+	kind  joinKind
+	on    *expression
+	using *[]*Token
+}
+
 type SelectStatement struct {
-	item  *[]*SelectItem
-	from  *Token
-	where *expression
+	item    *[]*SelectItem
+	from    *fromItem
+	where   *expression
+	groupBy *[]*expression
+	having  *expression
+	orderBy *[]*orderByItem
+	limit   *expression
+	offset  *expression
 }
 
 type binaryExpression struct {
@@ -65,3 +174,41 @@ type binaryExpression struct {
 	b  expression
 	op Token
 }
+
+type unaryExpression struct {
+	exp expression
+	op  Token
+}
+
+// castExpression converts exp to the type named by typ, typed literals
+// such as DATE '2024-01-01' are casts of a string
+type castExpression struct {
+	exp expression
+	typ Token
+}
+
+// callExpression calls a function. Aggregates may be called on the
+// distinct values of their argument, and count on * to count rows.
+type callExpression struct {
+	name     Token
+	args     *[]*expression
+	distinct bool
+	star     bool
+}
+
+type subqueryExpressionKind uint
+
+const (
+	scalarSubquery subqueryExpressionKind = iota
+	existsSubquery
+	inSubquery
+)
+
+// subqueryExpression uses the rows of a SELECT as a value. A scalar
+// subquery takes the value of its single row, EXISTS whether it returns
+// any row and IN whether exp is among the values it returns.
+type subqueryExpression struct {
+	slct *SelectStatement
+	exp  *expression
+	kind subqueryExpressionKind
+}
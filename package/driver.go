@@ -1,10 +1,15 @@
 package pck
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Driver struct {
@@ -19,6 +24,24 @@ func init() {
 	sql.Register("postgres", &Driver{NewMemoryBackend()})
 }
 
+// Connector opens connections to a backend of the caller's own, such as a
+// MemoryBackend with functions registered on it, for use with sql.OpenDB
+type Connector struct {
+	bkd Backend
+}
+
+func NewConnector(bkd Backend) *Connector {
+	return &Connector{bkd}
+}
+
+func (c *Connector) Connect(context.Context) (driver.Conn, error) {
+	return &Conn{c.bkd}, nil
+}
+
+func (c *Connector) Driver() driver.Driver {
+	return &Driver{c.bkd}
+}
+
 type Conn struct {
 	bkd Backend
 }
@@ -38,41 +61,120 @@ func (dc *Conn) Close() error {
 func (dc *Conn) Query(query string, args []driver.Value) (driver.Rows, error) {
 	if len(args) > 0 {
 		// TODO: support parameterization
-		panic("Parameterization not supported")
+		return nil, ErrParametersNotSupported
 	}
 
 	ast, err := Parse(query)
 	if err != nil {
-		return nil, fmt.Errorf("Error while parsing: %s", err)
+		return nil, fmt.Errorf("Error while parsing: %w", err)
 	}
 
 	// NOTE: ignorning all but the first statement
 	stmt := ast.Statements[0]
+	if stmt.Kind == SelectKind {
+		results, err := dc.bkd.Select(stmt.SelectStatement)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Rows{
+			rows:    results.Rows,
+			columns: results.Columns,
+			index:   0,
+		}, nil
+	}
+
+	_, err = dc.execute(stmt)
+	return nil, err
+}
+
+func (dc *Conn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	if len(args) > 0 {
+		// TODO: support parameterization
+		return nil, ErrParametersNotSupported
+	}
+
+	ast, err := Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("Error while parsing: %w", err)
+	}
+
+	// NOTE: ignorning all but the first statement
+	rowsAffected, err := dc.execute(ast.Statements[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{rowsAffected}, nil
+}
+
+// execute runs a statement for its side effects, returning the number
+// of rows it affected
+func (dc *Conn) execute(stmt *Statement) (int64, error) {
 	switch stmt.Kind {
 	case CreateTableKind:
-		err = dc.bkd.CreateTable(stmt.CreateTableStatement)
+		err := dc.bkd.CreateTable(stmt.CreateTableStatement)
+		if err != nil {
+			return 0, fmt.Errorf("Error creating table: %w", err)
+		}
+	case CreateIndexKind:
+		err := dc.bkd.CreateIndex(stmt.CreateIndexStatement)
+		if err != nil {
+			return 0, fmt.Errorf("Error creating index: %w", err)
+		}
+	case DropTableKind:
+		err := dc.bkd.DropTable(stmt.DropTableStatement)
+		if err != nil {
+			return 0, fmt.Errorf("Error dropping table: %w", err)
+		}
+	case DropIndexKind:
+		err := dc.bkd.DropIndex(stmt.DropIndexStatement)
 		if err != nil {
-			return nil, fmt.Errorf("Error creating table: %s", err)
+			return 0, fmt.Errorf("Error dropping index: %w", err)
 		}
 	case InsertKind:
-		err = dc.bkd.Insert(stmt.InsertStatement)
+		err := dc.bkd.Insert(stmt.InsertStatement)
 		if err != nil {
-			return nil, fmt.Errorf("Error inserting values: %s", err)
+			return 0, fmt.Errorf("Error inserting values: %w", err)
 		}
+
+		return 1, nil
+	case UpdateKind:
+		updated, err := dc.bkd.Update(stmt.UpdateStatement)
+		if err != nil {
+			return 0, fmt.Errorf("Error updating values: %w", err)
+		}
+
+		return int64(updated), nil
+	case DeleteKind:
+		deleted, err := dc.bkd.Delete(stmt.DeleteStatement)
+		if err != nil {
+			return 0, fmt.Errorf("Error deleting values: %w", err)
+		}
+
+		return int64(deleted), nil
 	case SelectKind:
 		results, err := dc.bkd.Select(stmt.SelectStatement)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
 
-		return &Rows{
-			rows:    results.Rows,
-			columns: results.Columns,
-			index:   0,
-		}, nil
+		return int64(len(results.Rows)), nil
 	}
 
-	return nil, nil
+	return 0, nil
+}
+
+type Result struct {
+	rowsAffected int64
+}
+
+func (r *Result) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId not supported")
+}
+
+func (r *Result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
 }
 
 type Rows struct {
@@ -90,6 +192,12 @@ func (r *Rows) Columns() []string {
 	return columns
 }
 
+// ColumnTypeDatabaseTypeName reports the type of a result column, which
+// is known even when the query returned no rows
+func (r *Rows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.columns[index].Type.String())
+}
+
 func (r *Rows) Close() error {
 	r.index = uint64(len(r.rows))
 	return nil
@@ -103,10 +211,39 @@ func (r *Rows) Next(dest []driver.Value) error {
 	row := r.rows[r.index]
 
 	for idx, cell := range row {
+		if cell.IsNull() {
+			dest[idx] = nil
+			continue
+		}
+
 		typ := r.columns[idx].Type
 		switch typ {
-		case IntType:
-			i := cell.AsInt()
+		case RealType:
+			// A real holds a float32 widened to float64, which carries
+			// digits the real never had: 0.1 would come out as
+			// 0.10000000149011612. Going through its shortest text gives
+			// the float64 it reads as.
+			f, err := strconv.ParseFloat(strconv.FormatFloat(cell.AsFloat64(), 'g', -1, 32), 64)
+			if err != nil {
+				return err
+			}
+
+			dest[idx] = f
+		case DoubleType:
+			dest[idx] = cell.AsFloat64()
+		case NumericType:
+			// Deliver the decimal text so no precision is lost
+			dest[idx] = cell.AsText()
+		case DateType, TimestampType, TimestampTzType:
+			dest[idx] = cell.AsTime()
+		case TimeType:
+			// Times of day go out on year 0 like other drivers do
+			t := cell.AsTime()
+			dest[idx] = time.Date(0, time.January, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		case IntervalType:
+			dest[idx] = cell.AsInterval().String()
+		case SmallIntType, IntType, BigIntType:
+			i := cell.AsInt64()
 			add := &i
 			if add == nil {
 				dest[idx] = i
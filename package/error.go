@@ -1,18 +1,62 @@
 package pck
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrTableDoesNotExist         = errors.New("Table does not exist")
 	ErrTableAlreadyExists        = errors.New("Table already exists")
 	ErrIndexAlreadyExists        = errors.New("Index already exists")
+	ErrIndexDoesNotExist         = errors.New("Index does not exist")
+	ErrIndexRequiredByConstraint = errors.New("Index is required by a PRIMARY KEY or UNIQUE constraint")
 	ErrViolatesUniqueConstraint  = errors.New("Duplicate key value violates unique constraint")
 	ErrViolatesNotNullConstraint = errors.New("Value violates not null constraint")
 	ErrColumnDoesNotExist        = errors.New("Column does not exist")
+	ErrColumnAlreadyExists       = errors.New("Column specified more than once")
 	ErrInvalidSelectItem         = errors.New("Select item is not valid")
 	ErrInvalidDatatype           = errors.New("Invalid datatype")
 	ErrMissingValues             = errors.New("Missing values")
 	ErrInvalidCell               = errors.New("Cell is invalid")
 	ErrInvalidOperands           = errors.New("Operands are invalid")
+	ErrDivisionByZero            = errors.New("Division by zero")
+	ErrIntegerOutOfRange         = errors.New("Integer out of range")
+	ErrFloatOutOfRange           = errors.New("Value out of range: overflow")
+	ErrNumericFieldOverflow      = errors.New("Numeric field overflow")
+	ErrInvalidTypeModifier       = errors.New("Invalid type modifier")
+	ErrInvalidDatetimeFormat     = errors.New("Invalid input syntax for date/time")
+	ErrDatetimeOutOfRange        = errors.New("Date/time value out of range")
+	ErrInvalidDatetimeUnit       = errors.New("Date/time unit not recognized")
+	ErrFunctionDoesNotExist      = errors.New("Function does not exist")
+	ErrAccessMethodDoesNotExist  = errors.New("Access method does not exist")
 	ErrPrimaryKeyAlreadyExists   = errors.New("Primary key already exists")
+	ErrInvalidLimit              = errors.New("Limit and offset must be non-negative integers")
+	ErrColumnNotGrouped          = errors.New("Column must appear in the GROUP BY clause or be used in an aggregate function")
+	ErrMisplacedAggregate        = errors.New("Aggregate functions are not allowed here")
+	ErrNegativeSubstringLength   = errors.New("Negative substring length not allowed")
+	ErrFunctionAlreadyExists     = errors.New("Function already exists")
+	ErrInvalidFunctionResult     = errors.New("Function returned a value of the wrong type")
+	ErrAmbiguousColumn           = errors.New("Column reference is ambiguous")
+	ErrDuplicateTableName        = errors.New("Table name specified more than once")
+	ErrSubqueryColumns           = errors.New("Subquery must return only one column")
+	ErrSubqueryRows              = errors.New("More than one row returned by a subquery used as an expression")
+	ErrParametersNotSupported    = errors.New("Query parameters are not supported")
+	ErrConditionNotBoolean       = errors.New("Condition must be of type boolean")
 )
+
+// DatatypeMismatchError is returned when a value can't be stored in a
+// column because it is of another type
+type DatatypeMismatchError struct {
+	Column   string
+	Expected ColumnType
+	Actual   ColumnType
+}
+
+func (e *DatatypeMismatchError) Error() string {
+	return fmt.Sprintf("Column %s is of type %s but expression is of type %s", e.Column, e.Expected, e.Actual)
+}
+
+func (e *DatatypeMismatchError) Unwrap() error {
+	return ErrInvalidDatatype
+}
@@ -14,32 +14,93 @@ type location struct {
 type keyword string
 
 const (
-	SelectKeyword     keyword = "select"
-	FromKeyword       keyword = "from"
-	AsKeyword         keyword = "as"
-	TableKeyword      keyword = "table"
-	CreateKeyword     keyword = "create"
-	DropKeyword       keyword = "drop"
-	InsertKeyword     keyword = "insert"
-	IntoKeyword       keyword = "into"
-	ValuesKeyword     keyword = "values"
-	IntKeyword        keyword = "int"
-	TextKeyword       keyword = "text"
-	BoolKeyword       keyword = "boolean"
-	WhereKeyword      keyword = "where"
-	AndKeyword        keyword = "and"
-	OrKeyword         keyword = "or"
-	TrueKeyword       keyword = "true"
-	FalseKeyword      keyword = "false"
-	UniqueKeyword     keyword = "unique"
-	IndexKeyword      keyword = "index"
-	OnKeyword         keyword = "on"
-	PrimarykeyKeyword keyword = "primary key"
-	NullKeyword       keyword = "null"
-	LimitKeyword      keyword = "limit"
-	OffsetKeyword     keyword = "offset"
+	SelectKeyword      keyword = "select"
+	FromKeyword        keyword = "from"
+	AsKeyword          keyword = "as"
+	TableKeyword       keyword = "table"
+	CreateKeyword      keyword = "create"
+	DropKeyword        keyword = "drop"
+	InsertKeyword      keyword = "insert"
+	IntoKeyword        keyword = "into"
+	ValuesKeyword      keyword = "values"
+	IntKeyword         keyword = "int"
+	IntegerKeyword     keyword = "integer"
+	SmallintKeyword    keyword = "smallint"
+	BigintKeyword      keyword = "bigint"
+	RealKeyword        keyword = "real"
+	DoubleKeyword      keyword = "double precision"
+	NumericKeyword     keyword = "numeric"
+	DecimalKeyword     keyword = "decimal"
+	DateKeyword        keyword = "date"
+	TimeKeyword        keyword = "time"
+	TimestampKeyword   keyword = "timestamp"
+	TimestamptzKeyword keyword = "timestamp with time zone"
+	IntervalKeyword    keyword = "interval"
+	TextKeyword        keyword = "text"
+	BoolKeyword        keyword = "boolean"
+	BoolAliasKeyword   keyword = "bool"
+	WhereKeyword       keyword = "where"
+	AndKeyword         keyword = "and"
+	OrKeyword          keyword = "or"
+	TrueKeyword        keyword = "true"
+	FalseKeyword       keyword = "false"
+	UniqueKeyword      keyword = "unique"
+	IndexKeyword       keyword = "index"
+	OnKeyword          keyword = "on"
+	PrimarykeyKeyword  keyword = "primary key"
+	NullKeyword        keyword = "null"
+	LimitKeyword       keyword = "limit"
+	OffsetKeyword      keyword = "offset"
+	NotKeyword         keyword = "not"
+	DefaultKeyword     keyword = "default"
+	IfKeyword          keyword = "if"
+	ExistsKeyword      keyword = "exists"
+	UpdateKeyword      keyword = "update"
+	SetKeyword         keyword = "set"
+	DeleteKeyword      keyword = "delete"
+	OrderKeyword       keyword = "order"
+	ByKeyword          keyword = "by"
+	AscKeyword         keyword = "asc"
+	DescKeyword        keyword = "desc"
+	NullsKeyword       keyword = "nulls"
+	FirstKeyword       keyword = "first"
+	LastKeyword        keyword = "last"
+	IsKeyword          keyword = "is"
+	UsingKeyword       keyword = "using"
+	GroupKeyword       keyword = "group"
+	HavingKeyword      keyword = "having"
+	DistinctKeyword    keyword = "distinct"
+	JoinKeyword        keyword = "join"
+	InnerKeyword       keyword = "inner"
+	LeftKeyword        keyword = "left"
+	RightKeyword       keyword = "right"
+	FullKeyword        keyword = "full"
+	OuterKeyword       keyword = "outer"
+	CrossKeyword       keyword = "cross"
+	InKeyword          keyword = "in"
 )
 
+// isUnreservedKeyword reports whether k is only a keyword where the
+// grammar expects it, such as FIRST after NULLS. Anywhere else it is a
+// name, so a column can be called first.
+func isUnreservedKeyword(k keyword) bool {
+	switch k {
+	case AscKeyword, DescKeyword, NullsKeyword, FirstKeyword, LastKeyword, SetKeyword, DefaultKeyword:
+		return true
+	// Type names, which are keywords in a column definition or before a
+	// string literal
+	case IntKeyword, TextKeyword, BoolKeyword, BoolAliasKeyword, IntegerKeyword, SmallintKeyword,
+		BigintKeyword, RealKeyword, NumericKeyword, DecimalKeyword,
+		DateKeyword, TimeKeyword, TimestampKeyword, IntervalKeyword:
+		return true
+	// Join kinds, which are keywords before JOIN
+	case InnerKeyword, LeftKeyword, RightKeyword, FullKeyword, OuterKeyword, CrossKeyword:
+		return true
+	}
+
+	return false
+}
+
 type symbol string
 
 const (
@@ -53,6 +114,9 @@ const (
 	NeqSymbol2       symbol = "!="
 	ConcatSymbol     symbol = "||"
 	PlusSymbol       symbol = "+"
+	MinusSymbol      symbol = "-"
+	SlashSymbol      symbol = "/"
+	PercentSymbol    symbol = "%"
 	LtSymbol         symbol = "<"
 	LteSymbol        symbol = "<="
 	GtSymbol         symbol = ">"
@@ -90,38 +154,57 @@ func (t Token) bindingPower() uint {
 	switch t.kind {
 	case keywordKind:
 		switch keyword(t.value) {
-		case AndKeyword:
-			fallthrough
 		case OrKeyword:
 			return 1
+		case AndKeyword:
+			return 2
+		case IsKeyword:
+			return 4
+		case InKeyword:
+			return 5
 		}
 	case symbolKind:
 		switch symbol(t.value) {
 		case EqSymbol:
 			fallthrough
 		case NeqSymbol:
-			return 2
-
+			fallthrough
 		case LtSymbol:
 			fallthrough
 		case GtSymbol:
-			return 3
-
+			fallthrough
 		case LteSymbol:
 			fallthrough
 		case GteSymbol:
-			return 4
+			return 5
 
 		case ConcatSymbol:
 			fallthrough
 		case PlusSymbol:
-			return 5
+			fallthrough
+		case MinusSymbol:
+			return 6
+
+		case asteriskSymbol:
+			fallthrough
+		case SlashSymbol:
+			fallthrough
+		case PercentSymbol:
+			return 7
 		}
 	}
 
 	return 0
 }
 
+const (
+	// notBindingPower makes NOT bind looser than comparisons and IS but
+	// tighter than AND
+	notBindingPower uint = 3
+	// unaryBindingPower binds unary minus tighter than any binary operator
+	unaryBindingPower uint = 8
+)
+
 type lexer func(string, cursor) (*Token, cursor, bool)
 
 func lex(source string) ([]*Token, error) {
@@ -208,7 +291,34 @@ func longestMatch(source string, ic cursor, options []string) string {
 	return match
 }
 
+// lexIdentifier lexes a name, which may be qualified with the name of
+// the table it belongs to as in users.name. The parts are kept together
+// in a single token.
 func lexIdentifier(source string, ic cursor) (*Token, cursor, bool) {
+	token, cur, ok := lexIdentifierPart(source, ic)
+	if !ok {
+		return nil, ic, false
+	}
+
+	for cur.pointer+1 < uint(len(source)) && source[cur.pointer] == '.' {
+		dot := cur
+		dot.pointer++
+		dot.loc.col++
+
+		part, newCursor, ok := lexIdentifierPart(source, dot)
+		if !ok {
+			break
+		}
+
+		token.value += "." + part.value
+		cur = newCursor
+	}
+
+	token.loc = ic.loc
+	return token, cur, true
+}
+
+func lexIdentifierPart(source string, ic cursor) (*Token, cursor, bool) {
 	// Handle separately if is a double-quoted identifier
 	if token, newCursor, ok := lexCharacterDelimited(source, ic, '"'); ok {
 		// Overwrite from stringkind to identifierkind
@@ -232,9 +342,7 @@ func lexIdentifier(source string, ic cursor) (*Token, cursor, bool) {
 		c = source[cur.pointer]
 
 		// Other characters count too, big ignoring non-ascii for now
-		isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
-		isNumeric := c >= '0' && c <= '9'
-		if isAlphabetical || isNumeric || c == '$' || c == '_' {
+		if isIdentifierChar(c) {
 			value = append(value, c)
 			cur.loc.col++
 			continue
@@ -251,6 +359,12 @@ func lexIdentifier(source string, ic cursor) (*Token, cursor, bool) {
 	}, cur, true
 }
 
+func isIdentifierChar(c byte) bool {
+	isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+	isNumeric := c >= '0' && c <= '9'
+	return isAlphabetical || isNumeric || c == '$' || c == '_'
+}
+
 func lexKeyword(source string, ic cursor) (*Token, cursor, bool) {
 	cur := ic
 	Keywords := []keyword{
@@ -265,7 +379,20 @@ func lexKeyword(source string, ic cursor) (*Token, cursor, bool) {
 		IntoKeyword,
 		TextKeyword,
 		BoolKeyword,
+		BoolAliasKeyword,
 		IntKeyword,
+		IntegerKeyword,
+		SmallintKeyword,
+		BigintKeyword,
+		RealKeyword,
+		DoubleKeyword,
+		NumericKeyword,
+		DecimalKeyword,
+		DateKeyword,
+		TimeKeyword,
+		TimestampKeyword,
+		TimestamptzKeyword,
+		IntervalKeyword,
 		AndKeyword,
 		OrKeyword,
 		AsKeyword,
@@ -278,6 +405,33 @@ func lexKeyword(source string, ic cursor) (*Token, cursor, bool) {
 		NullKeyword,
 		LimitKeyword,
 		OffsetKeyword,
+		NotKeyword,
+		DefaultKeyword,
+		IfKeyword,
+		ExistsKeyword,
+		UpdateKeyword,
+		SetKeyword,
+		DeleteKeyword,
+		OrderKeyword,
+		ByKeyword,
+		AscKeyword,
+		DescKeyword,
+		NullsKeyword,
+		FirstKeyword,
+		LastKeyword,
+		IsKeyword,
+		UsingKeyword,
+		GroupKeyword,
+		HavingKeyword,
+		DistinctKeyword,
+		JoinKeyword,
+		InnerKeyword,
+		LeftKeyword,
+		RightKeyword,
+		FullKeyword,
+		OuterKeyword,
+		CrossKeyword,
+		InKeyword,
 	}
 
 	var options []string
@@ -293,6 +447,13 @@ func lexKeyword(source string, ic cursor) (*Token, cursor, bool) {
 	cur.pointer = ic.pointer + uint(len(match))
 	cur.loc.col = ic.loc.col + uint(len(match))
 
+	// Keywords must end on a word boundary, otherwise an identifier like
+	// `orders` would lex as the keyword `or` followed by `ders`. A keyword
+	// followed by a dot is the table part of a qualified name.
+	if cur.pointer < uint(len(source)) && (isIdentifierChar(source[cur.pointer]) || source[cur.pointer] == '.') {
+		return nil, ic, false
+	}
+
 	Kind := keywordKind
 	if match == string(TrueKeyword) || match == string(FalseKeyword) {
 		Kind = boolKind
@@ -456,6 +617,9 @@ func lexSymbol(source string, ic cursor) (*Token, cursor, bool) {
 		GteSymbol,
 		ConcatSymbol,
 		PlusSymbol,
+		MinusSymbol,
+		SlashSymbol,
+		PercentSymbol,
 		commaSymbol,
 		leftparenSymbol,
 		rightparenSymbol,
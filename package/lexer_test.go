@@ -24,6 +24,10 @@ func TestLexNumeric(t *testing.T) {
 			number: true,
 			value:  "123.",
 		},
+		{
+			number: true,
+			value:  "1e-5",
+		},
 		// false tests
 		{
 			number: false,
@@ -80,13 +84,30 @@ func TestLexSymbol(t *testing.T) {
 		value  string
 	}{
 		{
-			symbol: false,
+			symbol: true,
 			value:  "= ",
 		},
 		{
-			symbol: false,
+			symbol: true,
 			value:  "||",
 		},
+		{
+			symbol: true,
+			value:  "-",
+		},
+		{
+			symbol: true,
+			value:  "/",
+		},
+		{
+			symbol: true,
+			value:  "%",
+		},
+		// false tests
+		{
+			symbol: false,
+			value:  "^",
+		},
 	}
 
 	for _, test := range tests {
@@ -120,6 +141,21 @@ func TestLexIdentifier(t *testing.T) {
 			input:      `"userName"`,
 			value:      "userName",
 		},
+		{
+			Identifier: true,
+			input:      `u.Name`,
+			value:      "u.name",
+		},
+		{
+			Identifier: true,
+			input:      `"Users"."userName" `,
+			value:      "Users.userName",
+		},
+		{
+			Identifier: true,
+			input:      "u.",
+			value:      "u",
+		},
 		// false tests
 		{
 			Identifier: false,
@@ -161,6 +197,42 @@ func TestLexKeyword(t *testing.T) {
 			keyword: true,
 			value:   "into",
 		},
+		{
+			keyword: true,
+			value:   "bool",
+		},
+		{
+			keyword: true,
+			value:   "boolean",
+		},
+		{
+			keyword: true,
+			value:   "bigint",
+		},
+		{
+			keyword: true,
+			value:   "SMALLINT",
+		},
+		{
+			keyword: true,
+			value:   "integer",
+		},
+		{
+			keyword: true,
+			value:   "double precision",
+		},
+		{
+			keyword: true,
+			value:   "numeric",
+		},
+		{
+			keyword: true,
+			value:   "timestamp with time zone",
+		},
+		{
+			keyword: true,
+			value:   "interval",
+		},
 		// false tests
 		{
 			keyword: false,
@@ -170,6 +242,14 @@ func TestLexKeyword(t *testing.T) {
 			keyword: false,
 			value:   "flubbrety",
 		},
+		{
+			keyword: false,
+			value:   "orders",
+		},
+		{
+			keyword: false,
+			value:   "first.name",
+		},
 	}
 
 	for _, test := range tests {
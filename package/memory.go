@@ -1,9 +1,12 @@
 package pck
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ColumnType uint
@@ -12,12 +15,92 @@ const (
 	TextType ColumnType = iota
 	IntType
 	BoolType
+	SmallIntType
+	BigIntType
+	RealType
+	DoubleType
+	NumericType
+	DateType
+	TimeType
+	TimestampType
+	TimestampTzType
+	IntervalType
 )
 
+func isIntegerType(c ColumnType) bool {
+	return c == SmallIntType || c == IntType || c == BigIntType
+}
+
+// intTypeRange returns the smallest and largest values an integer type
+// can hold
+func intTypeRange(c ColumnType) (int64, int64) {
+	switch c {
+	case SmallIntType:
+		return math.MinInt16, math.MaxInt16
+	case IntType:
+		return math.MinInt32, math.MaxInt32
+	default:
+		return math.MinInt64, math.MaxInt64
+	}
+}
+
+// widerIntType returns the integer type able to hold values of both a
+// and b
+func widerIntType(a, b ColumnType) ColumnType {
+	if a == BigIntType || b == BigIntType {
+		return BigIntType
+	} else if a == IntType || b == IntType {
+		return IntType
+	}
+
+	return SmallIntType
+}
+
+func (c ColumnType) String() string {
+	switch c {
+	case TextType:
+		return "text"
+	case IntType:
+		return "integer"
+	case BoolType:
+		return "boolean"
+	case SmallIntType:
+		return "smallint"
+	case BigIntType:
+		return "bigint"
+	case RealType:
+		return "real"
+	case DoubleType:
+		return "double precision"
+	case NumericType:
+		return "numeric"
+	case DateType:
+		return "date"
+	case TimeType:
+		return "time without time zone"
+	case TimestampType:
+		return "timestamp without time zone"
+	case TimestampTzType:
+		return "timestamp with time zone"
+	case IntervalType:
+		return "interval"
+	default:
+		return "unknown"
+	}
+}
+
 type Cell interface {
 	AsText() string
+	// Deprecated: AsInt truncates bigints, use AsInt64
 	AsInt() int32
+	AsInt64() int64
+	AsFloat64() float64
+	AsNumeric() *big.Rat
+	AsTime() time.Time
+	AsInterval() Interval
 	AsBool() bool
+	IsNull() bool
+	Type() ColumnType
 }
 
 type Results struct {
@@ -36,59 +119,237 @@ type Backend interface {
 	CreateTable(*CreateTableStatement) error
 	Insert(*InsertStatement) error
 	Select(*SelectStatement) (*Results, error)
+	CreateIndex(*CreateIndexStatement) error
+	DropTable(*DropTableStatement) error
+	DropIndex(*DropIndexStatement) error
+	Update(*UpdateStatement) (uint, error)
+	Delete(*DeleteStatement) (uint, error)
+}
+
+// MemoryCell is a single value tagged with its type, so reading it needs
+// no decoding. The zero value is NULL.
+type MemoryCell struct {
+	typ   ColumnType
+	valid bool
+
+	// i holds integers, booleans, days since 1970-01-01 for dates,
+	// microseconds for times and timestamps, the bits of floats and the
+	// microseconds of intervals
+	i int64
+
+	// aux holds the months and days of intervals
+	aux int64
+
+	// s holds text and the decimal digits of numerics, which keep the
+	// scale they were written with. num is the parsed numeric.
+	s   string
+	num *big.Rat
 }
 
-type MemoryCell []byte
+// Type returns the type of the value, which is meaningless for NULL
+func (mc MemoryCell) Type() ColumnType {
+	return mc.typ
+}
 
+// AsInt returns a smallint or an integer. A bigint outside the range of
+// an int32 is truncated.
+//
+// Deprecated: use AsInt64, which holds any integer.
 func (mc MemoryCell) AsInt() int32 {
-	var i int32
-	err := binary.Read(bytes.NewBuffer(mc), binary.BigEndian, &i)
-	if err != nil {
-		fmt.Printf("Corrupted data [%s]: %s\n", mc, err)
-		return 0
+	return int32(mc.i)
+}
+
+// AsInt64 returns a smallint, an integer or a bigint
+func (mc MemoryCell) AsInt64() int64 {
+	return mc.i
+}
+
+// AsFloat64 returns a real or a double precision
+func (mc MemoryCell) AsFloat64() float64 {
+	return math.Float64frombits(uint64(mc.i))
+}
+
+// AsNumeric returns a copy of a numeric
+func (mc MemoryCell) AsNumeric() *big.Rat {
+	if mc.num == nil {
+		return new(big.Rat)
+	}
+
+	return new(big.Rat).Set(mc.num)
+}
+
+// AsTime returns a date, time, timestamp or timestamp with time zone in
+// UTC. Times of day are returned on 1970-01-01.
+func (mc MemoryCell) AsTime() time.Time {
+	if mc.typ == DateType {
+		return time.Unix(mc.i*secondsPerDay, 0).UTC()
 	}
 
-	return i
+	return time.UnixMicro(mc.i).UTC()
+}
+
+func (mc MemoryCell) AsInterval() Interval {
+	return Interval{
+		Months:       int32(mc.aux >> 32),
+		Days:         int32(mc.aux),
+		Microseconds: mc.i,
+	}
 }
 
+// AsText returns text as is and renders values of any other type the way
+// PostgreSQL prints them
 func (mc MemoryCell) AsText() string {
-	return string(mc)
+	switch mc.typ {
+	case TextType, NumericType:
+		return mc.s
+	case SmallIntType, IntType, BigIntType:
+		return strconv.FormatInt(mc.i, 10)
+	case RealType, DoubleType:
+		return formatFloat(mc.AsFloat64(), mc.typ)
+	case BoolType:
+		return strconv.FormatBool(mc.AsBool())
+	case DateType, TimeType, TimestampType, TimestampTzType, IntervalType:
+		return formatTemporal(mc, mc.typ)
+	default:
+		return ""
+	}
 }
 
 func (mc MemoryCell) AsBool() bool {
-	return len(mc) != 0
+	return mc.valid && mc.i != 0
+}
+
+// IsNull reports whether the cell holds SQL NULL
+func (mc MemoryCell) IsNull() bool {
+	return !mc.valid
 }
 
+// equals compares two non-NULL cells of the same type, NULL never equals
+// anything so callers must check IsNull first
 func (mc MemoryCell) equals(b MemoryCell) bool {
-	// Seems verbose but need to make sure if one is nil, the
-	// comparison still fails quickly
-	if mc == nil || b == nil {
-		return mc == nil && b == nil
+	return mc.i == b.i && mc.aux == b.aux && mc.s == b.s
+}
+
+// encodeInt stores i as the integer type typ, i must be within the range
+// of typ
+func encodeInt(i int64, typ ColumnType) MemoryCell {
+	return MemoryCell{typ: typ, valid: true, i: i}
+}
+
+func textCell(s string) MemoryCell {
+	return MemoryCell{typ: TextType, valid: true, s: s}
+}
+
+// numericCell stores the decimal text of a numeric, s must be valid
+func numericCell(s string) MemoryCell {
+	num, ok := new(big.Rat).SetString(s)
+	if !ok {
+		fmt.Printf("Corrupted data [%s]: invalid numeric\n", s)
+		num = new(big.Rat)
 	}
 
-	return bytes.Compare(mc, b) == 0
+	return MemoryCell{typ: NumericType, valid: true, s: s, num: num}
 }
 
-var (
-	trueToken  = Token{kind: boolKind, value: "true"}
-	falseToken = Token{kind: boolKind, value: "false"}
+// compareCells orders two non-NULL cells of the same type, integers of
+// any width count as one type
+func compareCells(a, b MemoryCell, typ ColumnType) int {
+	switch typ {
+	case SmallIntType, IntType, BigIntType, DateType, TimeType, TimestampType, TimestampTzType:
+		ai, bi := a.AsInt64(), b.AsInt64()
+		if ai < bi {
+			return -1
+		} else if ai > bi {
+			return 1
+		}
+		return 0
+	case RealType, DoubleType:
+		af, bf := a.AsFloat64(), b.AsFloat64()
+		// NaN sorts above every other value and equals itself, as in
+		// PostgreSQL
+		if math.IsNaN(af) || math.IsNaN(bf) {
+			if math.IsNaN(af) && math.IsNaN(bf) {
+				return 0
+			} else if math.IsNaN(af) {
+				return 1
+			}
+			return -1
+		}
 
-	trueMemoryCell  = literalToMemoryCell(&trueToken)
-	falseMemoryCell = literalToMemoryCell(&falseToken)
+		if af < bf {
+			return -1
+		} else if af > bf {
+			return 1
+		}
+		return 0
+	case NumericType:
+		return a.num.Cmp(b.num)
+	case IntervalType:
+		return a.AsInterval().totalMicroseconds().Cmp(b.AsInterval().totalMicroseconds())
+	case BoolType:
+		ab, bb := a.AsBool(), b.AsBool()
+		if ab == bb {
+			return 0
+		} else if !ab {
+			return -1
+		}
+		return 1
+	default:
+		return strings.Compare(a.AsText(), b.AsText())
+	}
+}
+
+var (
+	trueMemoryCell  = MemoryCell{typ: BoolType, valid: true, i: 1}
+	falseMemoryCell = MemoryCell{typ: BoolType, valid: true, i: 0}
 )
 
+type columnConstraints struct {
+	notNull      bool
+	defaultValue *expression
+}
+
+// numericTypmod holds the precision and scale of a NUMERIC(p,s) column,
+// a zero precision means the column is unconstrained
+type numericTypmod struct {
+	precision int
+	scale     int
+}
+
 type table struct {
 	columns     []string
 	columnTypes []ColumnType
-	rows        [][]MemoryCell
+	typmods     []numericTypmod
+	constraints []columnConstraints
+
+	// qualifiers holds the name of the FROM item each column of a query
+	// comes from, which qualified references such as u.name are resolved
+	// with. Stored tables have none.
+	qualifiers []string
+	// qualifiedOnly marks the columns a JOIN ... USING merged into one,
+	// they can only be referred to qualified
+	qualifiedOnly []bool
+
+	// Cells are kept in rows, or in columnData for columnar tables
+	columnar   bool
+	rows       [][]MemoryCell
+	columnData [][]MemoryCell
+
+	indexes []*index
 }
 
 type MemoryBackend struct {
-	tables map[string]*table
+	tables    map[string]*table
+	functions *functionRegistry
+
+	// statementTime is when the statement being run started, which
+	// now() returns
+	statementTime time.Time
 }
 
 func NewMemoryBackend() *MemoryBackend {
 	return &MemoryBackend{
-		tables: map[string]*table{},
+		tables:    map[string]*table{},
+		functions: newFunctionRegistry(),
 	}
 }
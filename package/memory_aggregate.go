@@ -0,0 +1,761 @@
+package pck
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// accumulator folds the values of a group into the result of an
+// aggregate. NULLs are skipped before they reach it.
+type accumulator interface {
+	add(value MemoryCell) error
+	result() (MemoryCell, error)
+}
+
+type aggregateFunction struct {
+	// returnType gives the type of the aggregate over values of typ, or
+	// false if it can't take them
+	returnType func(typ ColumnType) (ColumnType, bool)
+	// start returns an accumulator for values of typ
+	start func(typ ColumnType) accumulator
+}
+
+var aggregateFunctions = map[string]aggregateFunction{
+	"count": {
+		returnType: func(ColumnType) (ColumnType, bool) {
+			return BigIntType, true
+		},
+		start: func(ColumnType) accumulator {
+			return &countAccumulator{}
+		},
+	},
+	"sum": {
+		returnType: func(typ ColumnType) (ColumnType, bool) {
+			switch typ {
+			case SmallIntType, IntType:
+				return BigIntType, true
+			case BigIntType, NumericType:
+				return NumericType, true
+			case RealType, DoubleType, IntervalType:
+				return typ, true
+			}
+
+			return 0, false
+		},
+		start: func(typ ColumnType) accumulator {
+			return newSumAccumulator(typ)
+		},
+	},
+	"avg": {
+		returnType: func(typ ColumnType) (ColumnType, bool) {
+			switch {
+			case isIntegerType(typ) || typ == NumericType:
+				return NumericType, true
+			case isFloatType(typ):
+				return DoubleType, true
+			case typ == IntervalType:
+				return IntervalType, true
+			}
+
+			return 0, false
+		},
+		start: func(typ ColumnType) accumulator {
+			return newAvgAccumulator(typ)
+		},
+	},
+	"min": {
+		returnType: func(typ ColumnType) (ColumnType, bool) {
+			return typ, true
+		},
+		start: func(typ ColumnType) accumulator {
+			return &extremeAccumulator{typ: typ, sign: -1}
+		},
+	},
+	"max": {
+		returnType: func(typ ColumnType) (ColumnType, bool) {
+			return typ, true
+		},
+		start: func(typ ColumnType) accumulator {
+			return &extremeAccumulator{typ: typ, sign: 1}
+		},
+	},
+}
+
+func (sc *scope) isAggregate(call callExpression) bool {
+	_, ok := sc.functions.aggregate(call.name.value)
+	return ok
+}
+
+type countAccumulator struct {
+	n int64
+}
+
+func (a *countAccumulator) add(MemoryCell) error {
+	a.n++
+	return nil
+}
+
+func (a *countAccumulator) result() (MemoryCell, error) {
+	return encodeInt(a.n, BigIntType), nil
+}
+
+// sumAccumulator adds up integers in an int64, bigints and numerics in a
+// big.Rat, floats in a float64 and intervals as an interval
+type sumAccumulator struct {
+	typ   ColumnType
+	empty bool
+
+	i     int64
+	r     *big.Rat
+	scale int
+	f     float64
+	iv    Interval
+}
+
+func newSumAccumulator(typ ColumnType) *sumAccumulator {
+	return &sumAccumulator{typ: typ, empty: true, r: new(big.Rat)}
+}
+
+func (a *sumAccumulator) add(value MemoryCell) error {
+	a.empty = false
+
+	var err error
+	switch a.typ {
+	case SmallIntType, IntType:
+		a.i, err = integerArithmetic(PlusSymbol, a.i, value.AsInt64())
+	case BigIntType:
+		a.r.Add(a.r, new(big.Rat).SetInt64(value.AsInt64()))
+	case NumericType:
+		a.r.Add(a.r, value.AsNumeric())
+		if scale := numericScale(value); scale > a.scale {
+			a.scale = scale
+		}
+	case RealType, DoubleType:
+		a.f, err = floatArithmetic(PlusSymbol, a.f, value.AsFloat64())
+	case IntervalType:
+		a.iv, err = addIntervals(a.iv, value.AsInterval(), 1)
+	}
+
+	return err
+}
+
+func (a *sumAccumulator) result() (MemoryCell, error) {
+	if a.empty {
+		return MemoryCell{}, nil
+	}
+
+	switch a.typ {
+	case SmallIntType, IntType:
+		return encodeInt(a.i, BigIntType), nil
+	case BigIntType, NumericType:
+		return numericCell(formatNumeric(a.r, a.scale)), nil
+	case RealType, DoubleType:
+		return encodeFloat(a.f, a.typ), nil
+	default:
+		return encodeInterval(a.iv), nil
+	}
+}
+
+// avgAccumulator divides the sum of the values by their count the way
+// the division operator of the result type does. Integers are summed as
+// numerics and reals as double precision.
+type avgAccumulator struct {
+	typ ColumnType
+	sum *sumAccumulator
+	n   int64
+}
+
+func newAvgAccumulator(typ ColumnType) *avgAccumulator {
+	sumType := typ
+	if isIntegerType(typ) {
+		sumType = NumericType
+	} else if typ == RealType {
+		sumType = DoubleType
+	}
+
+	return &avgAccumulator{typ: typ, sum: newSumAccumulator(sumType)}
+}
+
+func (a *avgAccumulator) add(value MemoryCell) error {
+	value, err := castNumberOrSelf(value, a.typ, a.sum.typ)
+	if err != nil {
+		return err
+	}
+
+	a.n++
+	return a.sum.add(value)
+}
+
+func (a *avgAccumulator) result() (MemoryCell, error) {
+	sum, err := a.sum.result()
+	if err != nil || sum.IsNull() {
+		return MemoryCell{}, err
+	}
+
+	switch a.sum.typ {
+	case NumericType:
+		return numericArithmetic(SlashSymbol, sum, numericCell(strconv.FormatInt(a.n, 10)))
+	case DoubleType:
+		f, err := floatArithmetic(SlashSymbol, sum.AsFloat64(), float64(a.n))
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		return encodeFloat(f, DoubleType), nil
+	default:
+		iv, err := scaleInterval(sum.AsInterval(), 1/float64(a.n))
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		return encodeInterval(iv), nil
+	}
+}
+
+// castNumberOrSelf converts numbers with castNumber and returns values
+// of other types as they are
+func castNumberOrSelf(value MemoryCell, from, to ColumnType) (MemoryCell, error) {
+	if from == to || !isNumericType(from) {
+		return value, nil
+	}
+
+	return castNumber(value, from, to)
+}
+
+// extremeAccumulator keeps the smallest value when sign is -1 and the
+// largest when it is 1
+type extremeAccumulator struct {
+	typ   ColumnType
+	sign  int
+	value MemoryCell
+}
+
+func (a *extremeAccumulator) add(value MemoryCell) error {
+	if a.value.IsNull() || compareCells(value, a.value, a.typ)*a.sign > 0 {
+		a.value = value
+	}
+
+	return nil
+}
+
+func (a *extremeAccumulator) result() (MemoryCell, error) {
+	return a.value, nil
+}
+
+// groupKey encodes cells so that the encodings of two lists are equal
+// exactly when the cells are, NULLs being equal to each other as GROUP BY
+// and DISTINCT want
+func groupKey(cells []MemoryCell) string {
+	key := []byte{}
+	for _, cell := range cells {
+		if cell.IsNull() {
+			key = append(key, 0)
+			continue
+		}
+
+		key = append(key, 1)
+		switch cell.Type() {
+		case NumericType:
+			// 1.0 and 1.00 are the same number
+			s := cell.num.RatString()
+			key = binary.BigEndian.AppendUint32(key, uint32(len(s)))
+			key = append(key, s...)
+		case RealType, DoubleType:
+			f := cell.AsFloat64()
+			if f == 0 {
+				// Fold -0 into 0
+				f = 0
+			}
+
+			key = binary.BigEndian.AppendUint64(key, math.Float64bits(f))
+		default:
+			key = binary.BigEndian.AppendUint64(key, uint64(cell.i))
+			key = binary.BigEndian.AppendUint64(key, uint64(cell.aux))
+			key = binary.BigEndian.AppendUint32(key, uint32(len(cell.s)))
+			key = append(key, cell.s...)
+		}
+	}
+
+	return string(key)
+}
+
+// containsAggregate reports whether exp calls an aggregate outside of the
+// arguments of another one
+func (sc *scope) containsAggregate(exp expression) bool {
+	switch exp.kind {
+	case binaryKind:
+		return sc.containsAggregate(exp.binary.a) || sc.containsAggregate(exp.binary.b)
+	case unaryKind:
+		return sc.containsAggregate(exp.unary.exp)
+	case castKind:
+		return sc.containsAggregate(exp.cast.exp)
+	case callKind:
+		if sc.isAggregate(*exp.call) {
+			return true
+		}
+
+		for _, arg := range *exp.call.args {
+			if sc.containsAggregate(*arg) {
+				return true
+			}
+		}
+	case subqueryKind:
+		// The subquery itself is a query of its own, only the operand of
+		// IN belongs to this one
+		if exp.subquery.exp != nil {
+			return sc.containsAggregate(*exp.subquery.exp)
+		}
+	}
+
+	return false
+}
+
+func (sc *scope) isAggregateQuery(slct *SelectStatement, items []*SelectItem) bool {
+	if slct.groupBy != nil || slct.having != nil {
+		return true
+	}
+
+	for _, item := range items {
+		if sc.containsAggregate(*item.Exp) {
+			return true
+		}
+	}
+
+	if slct.orderBy != nil {
+		for _, ob := range *slct.orderBy {
+			if sc.containsAggregate(*ob.exp) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// expressionsEqual reports whether a and b are written the same way, which
+// is how a select item is matched with a GROUP BY expression
+func expressionsEqual(a, b expression) bool {
+	if a.kind != b.kind {
+		return false
+	}
+
+	switch a.kind {
+	case literalKind:
+		return a.literal.equals(b.literal)
+	case binaryKind:
+		return a.binary.op.equals(&b.binary.op) && expressionsEqual(a.binary.a, b.binary.a) && expressionsEqual(a.binary.b, b.binary.b)
+	case unaryKind:
+		return a.unary.op.equals(&b.unary.op) && expressionsEqual(a.unary.exp, b.unary.exp)
+	case castKind:
+		return a.cast.typ.equals(&b.cast.typ) && expressionsEqual(a.cast.exp, b.cast.exp)
+	case callKind:
+		ac, bc := a.call, b.call
+		if !ac.name.equals(&bc.name) || ac.distinct != bc.distinct || ac.star != bc.star || len(*ac.args) != len(*bc.args) {
+			return false
+		}
+
+		for i, arg := range *ac.args {
+			if !expressionsEqual(*arg, *(*bc.args)[i]) {
+				return false
+			}
+		}
+
+		return true
+	case subqueryKind:
+		return a.subquery == b.subquery
+	}
+
+	return false
+}
+
+// sameColumn reports whether a and b refer to the same column, such as
+// name and u.name
+func (sc *scope) sameColumn(a, b expression) bool {
+	if a.kind != literalKind || a.literal.kind != identifierKind || b.kind != literalKind || b.literal.kind != identifierKind {
+		return false
+	}
+
+	ac, aerr := sc.t.columnIndex(a.literal.value)
+	bc, berr := sc.t.columnIndex(b.literal.value)
+	return aerr == nil && berr == nil && ac == bc
+}
+
+// expressionName is the name compileExpression gives the value of exp
+func (sc *scope) expressionName(exp expression) string {
+	switch {
+	case exp.kind == literalKind && exp.literal.kind == identifierKind:
+		if column, err := sc.t.columnIndex(exp.literal.value); err == nil {
+			return sc.t.columns[column]
+		}
+
+		return exp.literal.value
+	case exp.kind == callKind:
+		return exp.call.name.value
+	default:
+		return "?column?"
+	}
+}
+
+func columnReference(name string) expression {
+	return expression{
+		literal: &Token{
+			value: name,
+			kind:  identifierKind,
+		},
+		kind: literalKind,
+	}
+}
+
+// grouping collects the GROUP BY expressions and the aggregate calls of
+// a query while rewriting its expressions to refer to them
+type grouping struct {
+	sc         *scope
+	keys       []expression
+	aggregates []callExpression
+}
+
+func groupColumn(i int) string {
+	return fmt.Sprintf("?group%d", i)
+}
+
+func aggregateColumn(i int) string {
+	return fmt.Sprintf("?aggregate%d", i)
+}
+
+// rewrite replaces the GROUP BY expressions and aggregate calls in exp
+// with references to the columns of the grouped table. Any other column
+// has no single value for a group.
+func (g *grouping) rewrite(exp expression) (expression, error) {
+	for i, key := range g.keys {
+		if expressionsEqual(exp, key) || g.sc.sameColumn(exp, key) {
+			return columnReference(groupColumn(i)), nil
+		}
+	}
+
+	switch exp.kind {
+	case literalKind:
+		if exp.literal.kind != identifierKind {
+			return exp, nil
+		}
+
+		if _, err := g.sc.t.columnIndex(exp.literal.value); err == ErrColumnDoesNotExist && g.sc.outer != nil {
+			// A column of the enclosing query has a single value here
+			return exp, nil
+		} else if err != nil {
+			return expression{}, err
+		}
+
+		return expression{}, ErrColumnNotGrouped
+	case binaryKind:
+		a, err := g.rewrite(exp.binary.a)
+		if err != nil {
+			return expression{}, err
+		}
+
+		b, err := g.rewrite(exp.binary.b)
+		if err != nil {
+			return expression{}, err
+		}
+
+		return expression{
+			binary: &binaryExpression{a, b, exp.binary.op},
+			kind:   binaryKind,
+		}, nil
+	case unaryKind:
+		operand, err := g.rewrite(exp.unary.exp)
+		if err != nil {
+			return expression{}, err
+		}
+
+		return expression{
+			unary: &unaryExpression{operand, exp.unary.op},
+			kind:  unaryKind,
+		}, nil
+	case castKind:
+		operand, err := g.rewrite(exp.cast.exp)
+		if err != nil {
+			return expression{}, err
+		}
+
+		return expression{
+			cast: &castExpression{operand, exp.cast.typ},
+			kind: castKind,
+		}, nil
+	case callKind:
+		if g.sc.isAggregate(*exp.call) {
+			for i, call := range g.aggregates {
+				if expressionsEqual(exp, expression{call: &call, kind: callKind}) {
+					return columnReference(aggregateColumn(i)), nil
+				}
+			}
+
+			g.aggregates = append(g.aggregates, *exp.call)
+			return columnReference(aggregateColumn(len(g.aggregates) - 1)), nil
+		}
+
+		args := []*expression{}
+		for _, arg := range *exp.call.args {
+			rewritten, err := g.rewrite(*arg)
+			if err != nil {
+				return expression{}, err
+			}
+
+			args = append(args, &rewritten)
+		}
+
+		call := *exp.call
+		call.args = &args
+		return expression{call: &call, kind: callKind}, nil
+	case subqueryKind:
+		if exp.subquery.exp == nil {
+			return exp, nil
+		}
+
+		operand, err := g.rewrite(*exp.subquery.exp)
+		if err != nil {
+			return expression{}, err
+		}
+
+		sq := *exp.subquery
+		sq.exp = &operand
+		return expression{subquery: &sq, kind: subqueryKind}, nil
+	}
+
+	return exp, nil
+}
+
+// groupedSelect is an aggregate query turned into a plain select on a
+// table holding a row for each group, with the GROUP BY values and the
+// aggregate results as its columns
+type groupedSelect struct {
+	table    *table
+	grouping *grouping
+	items    []*SelectItem
+	names    []string
+	having   *expression
+	orderBy  *[]*orderByItem
+}
+
+// groupKeys resolves the GROUP BY expressions, which may also name a
+// select item by its alias or its position
+func (sc *scope) groupKeys(slct *SelectStatement, items []*SelectItem) ([]expression, error) {
+	keys := []expression{}
+	if slct.groupBy == nil {
+		return keys, nil
+	}
+
+	for _, exp := range *slct.groupBy {
+		key := *exp
+		if key.kind == literalKind && key.literal.kind == numericKind {
+			item, err := selectItemAt(*key.literal, items)
+			if err != nil {
+				return nil, err
+			}
+
+			key = *item.Exp
+		} else if key.kind == literalKind && key.literal.kind == identifierKind {
+			if _, err := sc.t.columnIndex(key.literal.value); err != nil {
+				for _, item := range items {
+					if item.As != nil && item.As.value == key.literal.value {
+						key = *item.Exp
+						break
+					}
+				}
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// group evaluates the aggregates of a query over the rows of t passing
+// its filter, grouped by its GROUP BY expressions. Without GROUP BY all
+// the rows form a single group, even when there are none.
+func (sc *scope) group(slct *SelectStatement, items []*SelectItem) (*groupedSelect, error) {
+	keys, err := sc.groupKeys(slct, items)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &grouping{sc: sc, keys: keys}
+	grouped := &groupedSelect{grouping: g}
+
+	for _, item := range items {
+		exp, err := g.rewrite(*item.Exp)
+		if err != nil {
+			return nil, err
+		}
+
+		grouped.items = append(grouped.items, &SelectItem{Exp: &exp, As: item.As})
+		grouped.names = append(grouped.names, sc.expressionName(*item.Exp))
+	}
+
+	if slct.having != nil {
+		having, err := g.rewrite(*slct.having)
+		if err != nil {
+			return nil, err
+		}
+
+		grouped.having = &having
+	}
+
+	if slct.orderBy != nil {
+		orderBy := []*orderByItem{}
+		for _, ob := range *slct.orderBy {
+			exp := *ob.exp
+
+			// Aliases are left for sortRows to resolve
+			isAlias := false
+			for _, item := range items {
+				isAlias = isAlias || (item.As != nil && exp.kind == literalKind && exp.literal.kind == identifierKind && item.As.value == exp.literal.value)
+			}
+
+			if !isAlias {
+				if exp, err = g.rewrite(exp); err != nil {
+					return nil, err
+				}
+			}
+
+			orderBy = append(orderBy, &orderByItem{exp: &exp, desc: ob.desc, nullsFirst: ob.nullsFirst})
+		}
+
+		grouped.orderBy = &orderBy
+	}
+
+	grouped.table = &table{}
+	result := grouped.table
+
+	compiledKeys := []compiledExpression{}
+	for i, key := range g.keys {
+		c, err := sc.compileExpression(key)
+		if err != nil {
+			return nil, err
+		}
+
+		compiledKeys = append(compiledKeys, c)
+		result.columns = append(result.columns, groupColumn(i))
+		result.columnTypes = append(result.columnTypes, c.typ)
+	}
+
+	args := []compiledExpression{}
+	aggregates := []aggregateFunction{}
+	for i, call := range g.aggregates {
+		aggregate, _ := sc.functions.aggregate(call.name.value)
+
+		// count(*) counts rows, which are never NULL
+		arg := constantExpression(trueMemoryCell, "?column?", BoolType)
+		if !call.star {
+			if len(*call.args) != 1 {
+				return nil, ErrFunctionDoesNotExist
+			}
+
+			if arg, err = sc.compileExpression(*(*call.args)[0]); err != nil {
+				return nil, err
+			}
+		}
+
+		typ, ok := aggregate.returnType(arg.typ)
+		if !ok {
+			return nil, ErrFunctionDoesNotExist
+		}
+
+		args = append(args, arg)
+		aggregates = append(aggregates, aggregate)
+		result.columns = append(result.columns, aggregateColumn(i))
+		result.columnTypes = append(result.columnTypes, typ)
+	}
+
+	result.typmods = make([]numericTypmod, len(result.columns))
+	result.constraints = make([]columnConstraints, len(result.columns))
+
+	matches, err := sc.filterRows(slct.where, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		key          []MemoryCell
+		accumulators []accumulator
+		// seen holds the values each DISTINCT aggregate already took
+		seen []map[string]bool
+	}
+
+	newGroup := func(key []MemoryCell) *group {
+		grp := &group{key: key}
+		for i, aggregate := range aggregates {
+			grp.accumulators = append(grp.accumulators, aggregate.start(args[i].typ))
+			grp.seen = append(grp.seen, map[string]bool{})
+		}
+
+		return grp
+	}
+
+	groups := []*group{}
+	groupIndexes := map[string]int{}
+	for _, rowIndex := range matches {
+		key := make([]MemoryCell, len(compiledKeys))
+		for i, c := range compiledKeys {
+			if key[i], err = c.eval(rowIndex); err != nil {
+				return nil, err
+			}
+		}
+
+		encoded := groupKey(key)
+		i, ok := groupIndexes[encoded]
+		if !ok {
+			i = len(groups)
+			groupIndexes[encoded] = i
+			groups = append(groups, newGroup(key))
+		}
+
+		grp := groups[i]
+		for j, arg := range args {
+			value, err := arg.eval(rowIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			if value.IsNull() {
+				continue
+			}
+
+			if g.aggregates[j].distinct {
+				encoded := groupKey([]MemoryCell{value})
+				if grp.seen[j][encoded] {
+					continue
+				}
+
+				grp.seen[j][encoded] = true
+			}
+
+			if err := grp.accumulators[j].add(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(groups) == 0 && len(g.keys) == 0 {
+		groups = append(groups, newGroup(nil))
+	}
+
+	for _, grp := range groups {
+		row := append([]MemoryCell{}, grp.key...)
+		for _, acc := range grp.accumulators {
+			value, err := acc.result()
+			if err != nil {
+				return nil, err
+			}
+
+			row = append(row, value)
+		}
+
+		result.appendRow(row)
+	}
+
+	return grouped, nil
+}
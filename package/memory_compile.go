@@ -0,0 +1,598 @@
+package pck
+
+import "time"
+
+// compiledExpression is an expression resolved against the columns of a
+// table. Columns are looked up, literals parsed and operand types checked
+// once when it is compiled, evaluating it for a row then only computes
+// the value.
+type compiledExpression struct {
+	name string
+	typ  ColumnType
+
+	// constant expressions refer to no column, they are evaluated once
+	// when compiled and value holds the result
+	constant bool
+	value    MemoryCell
+
+	// eval evaluates the expression for a single row and batch for the
+	// rows from start to end. The cells of a batch are reused by the next
+	// one, so they are only valid until then.
+	eval  func(rowIndex uint) (MemoryCell, error)
+	batch func(start, end uint) (vector, error)
+}
+
+// batchBuffer returns a slice of n cells, reusing buf when it is large
+// enough
+func batchBuffer(buf *[]MemoryCell, n uint) []MemoryCell {
+	if uint(cap(*buf)) < n {
+		*buf = make([]MemoryCell, n)
+	}
+
+	return (*buf)[:n]
+}
+
+// scope is what expressions are compiled against: the table whose rows
+// they are evaluated over and the functions they may call
+type scope struct {
+	t *table
+
+	// functions holds the functions registered on the backend, nil when
+	// only the built-in ones are available
+	functions *functionRegistry
+
+	// mb runs the subqueries of expressions, which can't have any when
+	// it is nil
+	mb *MemoryBackend
+	// outer is the row of the enclosing query a subquery is evaluated
+	// for, whose columns the subquery may refer to as well
+	outer *outerRow
+	// grouping is set when t holds the groups of an aggregate query, it
+	// maps the columns of the query's FROM to those of the groups
+	grouping *grouping
+}
+
+// statementTime returns when the statement being run started. Scopes
+// without a backend only evaluate constants outside of any statement.
+func (sc *scope) statementTime() time.Time {
+	if sc.mb == nil {
+		return time.Now()
+	}
+
+	return sc.mb.statementTime
+}
+
+func constantExpression(value MemoryCell, name string, typ ColumnType) compiledExpression {
+	return compiledExpression{
+		name:     name,
+		typ:      typ,
+		constant: true,
+		value:    value,
+		eval: func(uint) (MemoryCell, error) {
+			return value, nil
+		},
+		batch: func(uint, uint) (vector, error) {
+			return vector{cells: []MemoryCell{value}, typ: typ, constant: true}, nil
+		},
+	}
+}
+
+// rowExpression builds an expression out of eval alone, batches are
+// evaluated one row at a time
+func rowExpression(name string, typ ColumnType, eval func(rowIndex uint) (MemoryCell, error)) compiledExpression {
+	var buf []MemoryCell
+	return compiledExpression{
+		name: name,
+		typ:  typ,
+		eval: eval,
+		batch: func(start, end uint) (vector, error) {
+			cells := batchBuffer(&buf, end-start)
+			for i := start; i < end; i++ {
+				var err error
+				if cells[i-start], err = eval(i); err != nil {
+					return vector{}, err
+				}
+			}
+
+			return vector{cells: cells, typ: typ}, nil
+		},
+	}
+}
+
+// fold evaluates c once if all of its operands are constant
+func fold(c compiledExpression, operands ...compiledExpression) (compiledExpression, error) {
+	for _, operand := range operands {
+		if !operand.constant {
+			return c, nil
+		}
+	}
+
+	value, err := c.eval(0)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	return constantExpression(value, c.name, c.typ), nil
+}
+
+// compileCondition compiles the expression of a WHERE, HAVING or ON
+// clause, which must be a boolean or NULL
+func (sc *scope) compileCondition(exp expression) (compiledExpression, error) {
+	c, err := sc.compileExpression(exp)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	if c.typ != BoolType && !isNullLiteral(exp) {
+		return compiledExpression{}, ErrConditionNotBoolean
+	}
+
+	return c, nil
+}
+
+func (sc *scope) compileExpression(exp expression) (compiledExpression, error) {
+	switch exp.kind {
+	case literalKind:
+		return sc.compileLiteral(*exp.literal)
+	case binaryKind:
+		return sc.compileBinary(*exp.binary)
+	case unaryKind:
+		return sc.compileUnary(*exp.unary)
+	case castKind:
+		return sc.compileCast(*exp.cast)
+	case callKind:
+		return sc.compileCall(*exp.call)
+	case subqueryKind:
+		return sc.compileSubquery(*exp.subquery)
+	default:
+		return compiledExpression{}, ErrInvalidCell
+	}
+}
+
+func (sc *scope) compileLiteral(lit Token) (compiledExpression, error) {
+	switch lit.kind {
+	case identifierKind:
+		t := sc.t
+		column, err := t.columnIndex(lit.value)
+		if err == ErrColumnDoesNotExist && sc.outer != nil {
+			return sc.outer.column(lit)
+		} else if err != nil {
+			return compiledExpression{}, err
+		}
+
+		typ := t.columnTypes[column]
+		var buf []MemoryCell
+		return compiledExpression{
+			name: t.columns[column],
+			typ:  typ,
+			eval: func(rowIndex uint) (MemoryCell, error) {
+				return t.cell(rowIndex, column), nil
+			},
+			batch: func(start, end uint) (vector, error) {
+				if cells, ok := t.columnSlice(column, start, end); ok {
+					return vector{cells: cells, typ: typ}, nil
+				}
+
+				cells := batchBuffer(&buf, end-start)
+				for i := start; i < end; i++ {
+					cells[i-start] = t.cell(i, column)
+				}
+
+				return vector{cells: cells, typ: typ}, nil
+			},
+		}, nil
+	case numericKind:
+		value, typ, err := parseNumericLiteral(lit.value)
+		if err != nil {
+			return compiledExpression{}, err
+		}
+
+		return constantExpression(value, "?column?", typ), nil
+	case stringKind:
+		return constantExpression(literalToMemoryCell(&lit), "?column?", TextType), nil
+	case boolKind:
+		return constantExpression(literalToMemoryCell(&lit), "?column?", BoolType), nil
+	default:
+		// NULL is typed as an integer unless its context says otherwise
+		return constantExpression(MemoryCell{}, "?column?", IntType), nil
+	}
+}
+
+func (sc *scope) compileBinary(bexp binaryExpression) (compiledExpression, error) {
+	l, err := sc.compileExpression(bexp.a)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	r, err := sc.compileExpression(bexp.b)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	lt, rt := nullOperandTypes(bexp, l.typ, r.typ)
+
+	// Only literals can be untyped strings, and literals are constant
+	if l.constant {
+		value, typ, err := untypedOperand(bexp.op, bexp.a, l.value, lt, rt)
+		if err != nil {
+			return compiledExpression{}, err
+		}
+
+		l, lt = constantExpression(value, l.name, typ), typ
+	}
+
+	if r.constant {
+		value, typ, err := untypedOperand(bexp.op, bexp.b, r.value, rt, lt)
+		if err != nil {
+			return compiledExpression{}, err
+		}
+
+		r, rt = constantExpression(value, r.name, typ), typ
+	}
+
+	fromL, fromR := lt, rt
+	promoteTo, promote := promotedType(lt, rt)
+	if promote {
+		// Constants are promoted right away rather than for every row
+		if l.constant {
+			value, err := promoteOperand(l.value, fromL, promoteTo)
+			if err != nil {
+				return compiledExpression{}, err
+			}
+
+			l, fromL = constantExpression(value, l.name, promoteTo), promoteTo
+		}
+
+		if r.constant {
+			value, err := promoteOperand(r.value, fromR, promoteTo)
+			if err != nil {
+				return compiledExpression{}, err
+			}
+
+			r, fromR = constantExpression(value, r.name, promoteTo), promoteTo
+		}
+
+		lt, rt = promoteTo, promoteTo
+	}
+
+	// Applying the operator to NULLs checks the operand types and gives
+	// the type of the result without needing a row
+	_, typ, err := applyBinaryOperator(bexp, MemoryCell{}, MemoryCell{}, lt, rt)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	apply := binaryKernel(bexp, lt, rt)
+	if bexp.op.kind == keywordKind && (keyword(bexp.op.value) == AndKeyword || keyword(bexp.op.value) == OrKeyword) {
+		return fold(logicalExpression(bexp, l, r, apply), l, r)
+	}
+
+	var buf []MemoryCell
+
+	c := compiledExpression{
+		name: "?column?",
+		typ:  typ,
+		eval: func(rowIndex uint) (MemoryCell, error) {
+			lv, err := l.eval(rowIndex)
+			if err != nil {
+				return MemoryCell{}, err
+			}
+
+			rv, err := r.eval(rowIndex)
+			if err != nil {
+				return MemoryCell{}, err
+			}
+
+			if promote {
+				if lv, err = promoteOperand(lv, fromL, promoteTo); err != nil {
+					return MemoryCell{}, err
+				}
+
+				if rv, err = promoteOperand(rv, fromR, promoteTo); err != nil {
+					return MemoryCell{}, err
+				}
+			}
+
+			return apply(lv, rv)
+		},
+		batch: func(start, end uint) (vector, error) {
+			lv, err := l.batch(start, end)
+			if err != nil {
+				return vector{}, err
+			}
+
+			rv, err := r.batch(start, end)
+			if err != nil {
+				return vector{}, err
+			}
+
+			if promote {
+				if lv, err = lv.promote(fromL, promoteTo); err != nil {
+					return vector{}, err
+				}
+
+				if rv, err = rv.promote(fromR, promoteTo); err != nil {
+					return vector{}, err
+				}
+			}
+
+			cells := batchBuffer(&buf, end-start)
+			for i := range cells {
+				if cells[i], err = apply(lv.at(i), rv.at(i)); err != nil {
+					return vector{}, err
+				}
+			}
+
+			return vector{cells: cells, typ: typ}, nil
+		},
+	}
+
+	return fold(c, l, r)
+}
+
+// logicalExpression compiles AND and OR, which only evaluate their right
+// operand for the rows the left one doesn't decide: b <> 0 AND a / b > 1
+// never divides by zero. Their operands are booleans, so neither needs
+// promoting.
+func logicalExpression(bexp binaryExpression, l, r compiledExpression, apply func(l, r MemoryCell) (MemoryCell, error)) compiledExpression {
+	// false decides AND and true decides OR, whatever the right operand
+	decidedBy := keyword(bexp.op.value) == OrKeyword
+	decides := func(v MemoryCell) bool {
+		return !v.IsNull() && v.AsBool() == decidedBy
+	}
+
+	var buf []MemoryCell
+	return compiledExpression{
+		name: "?column?",
+		typ:  BoolType,
+		eval: func(rowIndex uint) (MemoryCell, error) {
+			lv, err := l.eval(rowIndex)
+			if err != nil || decides(lv) {
+				return lv, err
+			}
+
+			rv, err := r.eval(rowIndex)
+			if err != nil {
+				return MemoryCell{}, err
+			}
+
+			return apply(lv, rv)
+		},
+		batch: func(start, end uint) (vector, error) {
+			lv, err := l.batch(start, end)
+			if err != nil {
+				return vector{}, err
+			}
+
+			cells := batchBuffer(&buf, end-start)
+			for i := 0; i < len(cells); {
+				if decides(lv.at(i)) {
+					cells[i] = lv.at(i)
+					i++
+					continue
+				}
+
+				// The right operand is evaluated a run of undecided rows
+				// at a time
+				j := i + 1
+				for j < len(cells) && !decides(lv.at(j)) {
+					j++
+				}
+
+				rv, err := r.batch(start+uint(i), start+uint(j))
+				if err != nil {
+					return vector{}, err
+				}
+
+				for k := i; k < j; k++ {
+					if cells[k], err = apply(lv.at(k), rv.at(k-i)); err != nil {
+						return vector{}, err
+					}
+				}
+
+				i = j
+			}
+
+			return vector{cells: cells, typ: BoolType}, nil
+		},
+	}
+}
+
+// binaryKernel picks the function applying the operator of bexp to
+// operands of types lt and rt. Comparisons of values held as integers,
+// which covers dates, times and timestamps, skip applyBinaryOperator.
+func binaryKernel(bexp binaryExpression, lt, rt ColumnType) func(l, r MemoryCell) (MemoryCell, error) {
+	generic := func(l, r MemoryCell) (MemoryCell, error) {
+		value, _, err := applyBinaryOperator(bexp, l, r, lt, rt)
+		return value, err
+	}
+
+	isInteger := func(c ColumnType) bool {
+		return isIntegerType(c) || c == DateType || c == TimeType || c == TimestampType || c == TimestampTzType
+	}
+
+	if bexp.op.kind != symbolKind {
+		return generic
+	}
+
+	if !(isIntegerType(lt) && isIntegerType(rt)) && !(lt == rt && isInteger(lt)) {
+		return generic
+	}
+
+	var matches func(a, b int64) bool
+	switch symbol(bexp.op.value) {
+	case EqSymbol:
+		matches = func(a, b int64) bool { return a == b }
+	case NeqSymbol:
+		matches = func(a, b int64) bool { return a != b }
+	case LtSymbol:
+		matches = func(a, b int64) bool { return a < b }
+	case LteSymbol:
+		matches = func(a, b int64) bool { return a <= b }
+	case GtSymbol:
+		matches = func(a, b int64) bool { return a > b }
+	case GteSymbol:
+		matches = func(a, b int64) bool { return a >= b }
+	default:
+		return generic
+	}
+
+	return func(l, r MemoryCell) (MemoryCell, error) {
+		if l.IsNull() || r.IsNull() {
+			return MemoryCell{}, nil
+		}
+
+		return boolToMemoryCell(matches(l.AsInt64(), r.AsInt64())), nil
+	}
+}
+
+func (sc *scope) compileUnary(uexp unaryExpression) (compiledExpression, error) {
+	v, err := sc.compileExpression(uexp.exp)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	_, typ, err := applyUnaryOperator(uexp, MemoryCell{}, v.typ)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	c := rowExpression("?column?", typ, func(rowIndex uint) (MemoryCell, error) {
+		value, err := v.eval(rowIndex)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		value, _, err = applyUnaryOperator(uexp, value, v.typ)
+		return value, err
+	})
+
+	return fold(c, v)
+}
+
+func (sc *scope) compileCast(cexp castExpression) (compiledExpression, error) {
+	v, err := sc.compileExpression(cexp.exp)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	typ, ok := columnTypeFromName(cexp.typ.value)
+	if !ok {
+		return compiledExpression{}, ErrInvalidDatatype
+	}
+
+	untyped := isStringLiteral(cexp.exp)
+	c := rowExpression("?column?", typ, func(rowIndex uint) (MemoryCell, error) {
+		value, err := v.eval(rowIndex)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		return coerceCell(value, v.typ, typ, untyped)
+	})
+
+	return fold(c, v)
+}
+
+func (sc *scope) compileCall(call callExpression) (compiledExpression, error) {
+	// The grouping stage took the aggregates out of every expression that
+	// may hold them
+	if sc.isAggregate(call) {
+		return compiledExpression{}, ErrMisplacedAggregate
+	}
+
+	fn, ok := sc.functions.scalar(call.name.value)
+	if !ok || call.distinct || call.star {
+		return compiledExpression{}, ErrFunctionDoesNotExist
+	}
+
+	args := []compiledExpression{}
+	described := []argument{}
+	for _, arg := range *call.args {
+		c, err := sc.compileExpression(*arg)
+		if err != nil {
+			return compiledExpression{}, err
+		}
+
+		args = append(args, c)
+		described = append(described, argument{
+			typ:     c.typ,
+			null:    isNullLiteral(*arg),
+			untyped: isStringLiteral(*arg),
+		})
+	}
+
+	types, typ, ok := fn.resolve(described)
+	if !ok {
+		return compiledExpression{}, ErrFunctionDoesNotExist
+	}
+
+	for i := range args {
+		var err error
+		if args[i], err = convertArgument(args[i], described[i], types[i]); err != nil {
+			return compiledExpression{}, err
+		}
+	}
+
+	if fn.current != nil {
+		value, err := fn.current(sc.statementTime())
+		if err != nil {
+			return compiledExpression{}, err
+		}
+
+		return constantExpression(value, call.name.value, typ), nil
+	}
+
+	if fn.lazy != nil {
+		c := rowExpression(call.name.value, typ, func(rowIndex uint) (MemoryCell, error) {
+			return fn.lazy(len(args), func(i int) (MemoryCell, error) {
+				return args[i].eval(rowIndex)
+			})
+		})
+
+		return fold(c, args...)
+	}
+
+	c := rowExpression(call.name.value, typ, func(rowIndex uint) (MemoryCell, error) {
+		values := make([]MemoryCell, len(args))
+		for i, arg := range args {
+			var err error
+			if values[i], err = arg.eval(rowIndex); err != nil {
+				return MemoryCell{}, err
+			}
+
+			if fn.strict && values[i].IsNull() {
+				return MemoryCell{}, nil
+			}
+		}
+
+		return fn.call(values, types, typ)
+	})
+
+	return fold(c, args...)
+}
+
+// convertArgument converts the values of c to the type a function takes
+func convertArgument(c compiledExpression, arg argument, to ColumnType) (compiledExpression, error) {
+	if c.typ == to && !arg.untyped {
+		return c, nil
+	}
+
+	if c.constant {
+		value, err := coerceCell(c.value, c.typ, to, arg.untyped)
+		if err != nil {
+			return compiledExpression{}, err
+		}
+
+		return constantExpression(value, c.name, to), nil
+	}
+
+	return rowExpression(c.name, to, func(rowIndex uint) (MemoryCell, error) {
+		value, err := c.eval(rowIndex)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		return coerceCell(value, c.typ, to, false)
+	}), nil
+}
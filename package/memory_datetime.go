@@ -0,0 +1,762 @@
+package pck
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	microsPerSecond = int64(time.Second / time.Microsecond)
+	microsPerMinute = 60 * microsPerSecond
+	microsPerHour   = 60 * microsPerMinute
+	microsPerDay    = 24 * microsPerHour
+	secondsPerDay   = 24 * 60 * 60
+
+	// daysPerMonth is how long a month is taken to be when intervals are
+	// compared or months have to be spilled into days
+	daysPerMonth = 30
+
+	// minYear and maxYear bound dates and timestamps like in PostgreSQL,
+	// 4713 BC is year -4712
+	minYear = -4712
+	maxYear = 294276
+)
+
+// Interval is a span of time kept the way PostgreSQL keeps it, months
+// and days are separate from the rest because their length varies
+type Interval struct {
+	Months       int32
+	Days         int32
+	Microseconds int64
+}
+
+func (iv Interval) String() string {
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, unit)
+		}
+
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	parts := []string{}
+	if years := int64(iv.Months / 12); years != 0 {
+		parts = append(parts, plural(years, "year"))
+	}
+
+	if months := int64(iv.Months % 12); months != 0 {
+		parts = append(parts, plural(months, "mon"))
+	}
+
+	if iv.Days != 0 {
+		parts = append(parts, plural(int64(iv.Days), "day"))
+	}
+
+	if iv.Microseconds != 0 || len(parts) == 0 {
+		parts = append(parts, formatTimeOfDay(iv.Microseconds))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// totalMicroseconds flattens the interval using 30 day months, which is
+// how intervals are ordered
+func (iv Interval) totalMicroseconds() *big.Int {
+	days := big.NewInt(int64(iv.Months)*daysPerMonth + int64(iv.Days))
+	total := new(big.Int).Mul(days, big.NewInt(microsPerDay))
+	return total.Add(total, big.NewInt(iv.Microseconds))
+}
+
+func isTemporalType(c ColumnType) bool {
+	return isDatetimeType(c) || c == TimeType || c == IntervalType
+}
+
+// isDatetimeType reports whether c holds points in time, which can be
+// converted into each other
+func isDatetimeType(c ColumnType) bool {
+	return c == DateType || c == TimestampType || c == TimestampTzType
+}
+
+// commonDatetimeType returns the type two points in time are converted
+// to before they are compared or combined
+func commonDatetimeType(a, b ColumnType) ColumnType {
+	if a == TimestampTzType || b == TimestampTzType {
+		return TimestampTzType
+	}
+
+	if a == DateType && b == DateType {
+		return DateType
+	}
+
+	return TimestampType
+}
+
+func checkDatetimeRange(t time.Time) error {
+	if t.Year() < minYear || t.Year() > maxYear {
+		return ErrDatetimeOutOfRange
+	}
+
+	return nil
+}
+
+// encodeDate stores the day t falls on as the number of days since
+// 1970-01-01
+func encodeDate(t time.Time) (MemoryCell, error) {
+	if err := checkDatetimeRange(t); err != nil {
+		return MemoryCell{}, err
+	}
+
+	days := t.Unix() / secondsPerDay
+	if t.Unix()%secondsPerDay < 0 {
+		days--
+	}
+
+	return MemoryCell{typ: DateType, valid: true, i: days}, nil
+}
+
+// encodeTimestamp stores t as microseconds since 1970-01-01 UTC, which is
+// used for timestamps with and without time zone alike
+func encodeTimestamp(t time.Time, typ ColumnType) (MemoryCell, error) {
+	if err := checkDatetimeRange(t); err != nil {
+		return MemoryCell{}, err
+	}
+
+	return MemoryCell{typ: typ, valid: true, i: t.UnixMicro()}, nil
+}
+
+// encodeTime stores a time of day as microseconds since midnight,
+// wrapping around past midnight
+func encodeTime(micros int64) MemoryCell {
+	micros %= microsPerDay
+	if micros < 0 {
+		micros += microsPerDay
+	}
+
+	return MemoryCell{typ: TimeType, valid: true, i: micros}
+}
+
+func encodeInterval(iv Interval) MemoryCell {
+	return MemoryCell{
+		typ:   IntervalType,
+		valid: true,
+		i:     iv.Microseconds,
+		aux:   int64(iv.Months)<<32 | int64(uint32(iv.Days)),
+	}
+}
+
+func parseDate(s string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err == nil {
+		return t, nil
+	}
+
+	// A timestamp is accepted too, the time of day is dropped
+	t, err = parseTimestamp(s, false)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// parseTimestamp reads an ISO 8601 timestamp. An offset is applied for
+// timestamps with time zone and ignored otherwise, timestamps without an
+// offset are in UTC.
+func parseTimestamp(s string, withZone bool) (time.Time, error) {
+	layouts := []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02T15:04",
+	}
+
+	for _, layout := range layouts {
+		for _, zone := range []string{"Z07:00", "Z0700", "Z07", " Z07:00", " Z07", " MST"} {
+			t, err := time.Parse(layout+zone, s)
+			if err != nil {
+				continue
+			}
+
+			if !withZone {
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+			}
+
+			return t.UTC(), nil
+		}
+
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, ErrInvalidDatetimeFormat
+	}
+
+	return t, nil
+}
+
+// parseTime reads a time of day into microseconds since midnight
+func parseTime(s string) (int64, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+
+		return int64(t.Hour())*microsPerHour + int64(t.Minute())*microsPerMinute +
+			int64(t.Second())*microsPerSecond + int64(t.Nanosecond()/1000), nil
+	}
+
+	return 0, ErrInvalidDatetimeFormat
+}
+
+// parseClock reads the [-]HH:MM[:SS[.ffffff]] part of an interval
+func parseClock(s string) (int64, bool) {
+	negative := strings.HasPrefix(s, "-")
+	parts := strings.Split(strings.TrimLeft(s, "+-"), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+
+	hours, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	minutes, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || minutes >= 60 {
+		return 0, false
+	}
+
+	seconds := 0.0
+	if len(parts) == 3 {
+		seconds, err = strconv.ParseFloat(parts[2], 64)
+		if err != nil || seconds >= 60 {
+			return 0, false
+		}
+	}
+
+	micros := hours*microsPerHour + minutes*microsPerMinute + int64(math.Round(seconds*float64(microsPerSecond)))
+	if negative {
+		micros = -micros
+	}
+
+	return micros, true
+}
+
+// parseInterval reads the PostgreSQL interval syntax, a list of
+// quantities and units such as '1 year 2 mons 3 days 04:05:06'. A
+// trailing ago negates the whole interval.
+func parseInterval(s string) (Interval, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) > 0 && fields[0] == "@" {
+		fields = fields[1:]
+	}
+
+	ago := len(fields) > 0 && fields[len(fields)-1] == "ago"
+	if ago {
+		fields = fields[:len(fields)-1]
+	}
+
+	if len(fields) == 0 {
+		return Interval{}, ErrInvalidDatetimeFormat
+	}
+
+	// Units are in microseconds, or in months for months and up. Fractions
+	// of a month spill into days and fractions of a day into microseconds.
+	units := map[string]float64{
+		"microsecond": 1, "microseconds": 1, "us": 1,
+		"millisecond": 1e3, "milliseconds": 1e3, "ms": 1e3,
+		"second": 1e6, "seconds": 1e6, "sec": 1e6, "secs": 1e6, "s": 1e6,
+		"minute": 6e7, "minutes": 6e7, "min": 6e7, "mins": 6e7, "m": 6e7,
+		"hour": 36e8, "hours": 36e8, "hr": 36e8, "hrs": 36e8, "h": 36e8,
+	}
+	dayUnits := map[string]float64{
+		"day": 1, "days": 1, "d": 1,
+		"week": 7, "weeks": 7, "w": 7,
+	}
+	monthUnits := map[string]float64{
+		"month": 1, "months": 1, "mon": 1, "mons": 1,
+		"year": 12, "years": 12, "yr": 12, "yrs": 12, "y": 12,
+		"decade": 120, "decades": 120,
+		"century": 1200, "centuries": 1200,
+		"millennium": 12000, "millennia": 12000,
+	}
+
+	var months, days, micros float64
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if clock, ok := parseClock(field); ok {
+			micros += float64(clock)
+			continue
+		}
+
+		// Allow the unit to follow the quantity directly, as in 10s
+		number, unit := field, ""
+		if j := strings.IndexFunc(field, func(r rune) bool { return r >= 'a' && r <= 'z' }); j > 0 {
+			number, unit = field[:j], field[j:]
+		} else if i+1 < len(fields) {
+			if _, err := strconv.ParseFloat(fields[i+1], 64); err != nil {
+				i++
+				unit = fields[i]
+			}
+		}
+
+		n, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return Interval{}, ErrInvalidDatetimeFormat
+		}
+
+		if unit == "" {
+			// A bare number is a number of seconds
+			unit = "second"
+		}
+
+		if u, ok := units[unit]; ok {
+			micros += n * u
+		} else if u, ok := dayUnits[unit]; ok {
+			days += n * u
+		} else if u, ok := monthUnits[unit]; ok {
+			months += n * u
+		} else {
+			return Interval{}, ErrInvalidDatetimeUnit
+		}
+	}
+
+	wholeMonths := math.Trunc(months)
+	days += (months - wholeMonths) * daysPerMonth
+	wholeDays := math.Trunc(days)
+	micros += (days - wholeDays) * float64(microsPerDay)
+	micros = math.Round(micros)
+
+	if math.Abs(wholeMonths) > math.MaxInt32 || math.Abs(wholeDays) > math.MaxInt32 || math.Abs(micros) >= math.MaxInt64 {
+		return Interval{}, ErrDatetimeOutOfRange
+	}
+
+	iv := Interval{
+		Months:       int32(wholeMonths),
+		Days:         int32(wholeDays),
+		Microseconds: int64(micros),
+	}
+
+	if ago {
+		iv = Interval{-iv.Months, -iv.Days, -iv.Microseconds}
+	}
+
+	return iv, nil
+}
+
+// parseTemporal reads the text of a date, time, timestamp or interval
+func parseTemporal(s string, typ ColumnType) (MemoryCell, error) {
+	switch typ {
+	case DateType:
+		t, err := parseDate(s)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		return encodeDate(t)
+	case TimeType:
+		micros, err := parseTime(s)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		return encodeTime(micros), nil
+	case IntervalType:
+		iv, err := parseInterval(s)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		return encodeInterval(iv), nil
+	default:
+		t, err := parseTimestamp(s, typ == TimestampTzType)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		return encodeTimestamp(t, typ)
+	}
+}
+
+// formatTimeOfDay renders microseconds as [-]HH:MM:SS[.ffffff], hours
+// may go past 24
+func formatTimeOfDay(micros int64) string {
+	sign := ""
+	if micros < 0 {
+		sign = "-"
+		micros = -micros
+	}
+
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, micros/microsPerHour, micros%microsPerHour/microsPerMinute, micros%microsPerMinute/microsPerSecond)
+	if fraction := micros % microsPerSecond; fraction != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%06d", fraction), "0")
+	}
+
+	return s
+}
+
+// formatTemporal renders a temporal cell the way PostgreSQL prints it,
+// timestamps with time zone are shown in UTC
+func formatTemporal(value Cell, typ ColumnType) string {
+	switch typ {
+	case DateType:
+		return value.AsTime().Format("2006-01-02")
+	case TimeType:
+		return formatTimeOfDay(value.AsInt64())
+	case TimestampType:
+		return value.AsTime().Format("2006-01-02 15:04:05.999999")
+	case TimestampTzType:
+		return value.AsTime().Format("2006-01-02 15:04:05.999999-07")
+	case IntervalType:
+		return value.AsInterval().String()
+	}
+
+	return ""
+}
+
+// castDatetime converts a non-NULL point in time between date, timestamp
+// and timestamp with time zone. Timestamps without time zone are taken
+// to be in UTC.
+func castDatetime(value MemoryCell, from, to ColumnType) (MemoryCell, error) {
+	if from == to {
+		return value, nil
+	}
+
+	if to == DateType {
+		return encodeDate(value.AsTime())
+	}
+
+	return encodeTimestamp(value.AsTime(), to)
+}
+
+// addMonths moves t by a number of months, clamping the day to the end of
+// shorter months so Jan 31 plus a month is Feb 28 or 29
+func addMonths(t time.Time, months int) time.Time {
+	total := int(t.Month()) - 1 + months
+	year := t.Year() + total/12
+	month := total % 12
+	if month < 0 {
+		month += 12
+		year--
+	}
+
+	day := t.Day()
+	if last := time.Date(year, time.Month(month+2), 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+		day = last
+	}
+
+	return time.Date(year, time.Month(month+1), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// addInterval moves t by sign times iv, months first, then days and then
+// the rest
+func addInterval(t time.Time, iv Interval, sign int64) (time.Time, error) {
+	t = addMonths(t, int(sign*int64(iv.Months)))
+	t = t.AddDate(0, 0, int(sign*int64(iv.Days)))
+
+	micros, err := integerArithmetic(PlusSymbol, t.UnixMicro(), sign*iv.Microseconds)
+	if err != nil {
+		return time.Time{}, ErrDatetimeOutOfRange
+	}
+
+	return time.UnixMicro(micros).UTC(), nil
+}
+
+func addIntervals(a, b Interval, sign int64) (Interval, error) {
+	months := int64(a.Months) + sign*int64(b.Months)
+	days := int64(a.Days) + sign*int64(b.Days)
+	micros, err := integerArithmetic(PlusSymbol, a.Microseconds, sign*b.Microseconds)
+	if err != nil || months < math.MinInt32 || months > math.MaxInt32 || days < math.MinInt32 || days > math.MaxInt32 {
+		return Interval{}, ErrDatetimeOutOfRange
+	}
+
+	return Interval{int32(months), int32(days), micros}, nil
+}
+
+// scaleInterval multiplies iv by f, fractions of months spill into days
+// and fractions of days into microseconds
+func scaleInterval(iv Interval, f float64) (Interval, error) {
+	months := float64(iv.Months) * f
+	days := float64(iv.Days) * f
+	micros := float64(iv.Microseconds) * f
+
+	wholeMonths := math.Trunc(months)
+	days += (months - wholeMonths) * daysPerMonth
+	wholeDays := math.Trunc(days)
+	micros = math.Round(micros + (days-wholeDays)*float64(microsPerDay))
+
+	if math.IsNaN(micros) || math.Abs(wholeMonths) > math.MaxInt32 || math.Abs(wholeDays) > math.MaxInt32 || math.Abs(micros) >= math.MaxInt64 {
+		return Interval{}, ErrDatetimeOutOfRange
+	}
+
+	return Interval{int32(wholeMonths), int32(wholeDays), int64(micros)}, nil
+}
+
+// temporalResultType returns the type of applying an arithmetic operator
+// to temporal operands, following PostgreSQL's operators
+func temporalResultType(op symbol, lt, rt ColumnType) (ColumnType, bool) {
+	additive := op == PlusSymbol || op == MinusSymbol
+	switch {
+	case lt == DateType && isIntegerType(rt) && additive:
+		return DateType, true
+	case isIntegerType(lt) && rt == DateType && op == PlusSymbol:
+		return DateType, true
+	case lt == DateType && rt == DateType && op == MinusSymbol:
+		return IntType, true
+	case lt == DateType && rt == IntervalType && additive:
+		return TimestampType, true
+	case lt == IntervalType && rt == DateType && op == PlusSymbol:
+		return TimestampType, true
+	case (lt == TimestampType || lt == TimestampTzType || lt == TimeType) && rt == IntervalType && additive:
+		return lt, true
+	case lt == IntervalType && (rt == TimestampType || rt == TimestampTzType || rt == TimeType) && op == PlusSymbol:
+		return rt, true
+	case lt == rt && (lt == TimestampType || lt == TimestampTzType || lt == TimeType) && op == MinusSymbol:
+		return IntervalType, true
+	case lt == IntervalType && rt == IntervalType && additive:
+		return IntervalType, true
+	case lt == IntervalType && isNumericType(rt) && (op == asteriskSymbol || op == SlashSymbol):
+		return IntervalType, true
+	case isNumericType(lt) && rt == IntervalType && op == asteriskSymbol:
+		return IntervalType, true
+	}
+
+	return 0, false
+}
+
+// temporalArithmetic applies an arithmetic operator to non-NULL operands
+// temporalResultType accepted
+func temporalArithmetic(op symbol, l, r MemoryCell, lt, rt ColumnType) (MemoryCell, error) {
+	sign := int64(1)
+	if op == MinusSymbol {
+		sign = -1
+	}
+
+	// Put the temporal operand first for commutative operators
+	if op == PlusSymbol && (isIntegerType(lt) || (lt == IntervalType && rt != IntervalType)) {
+		l, r, lt, rt = r, l, rt, lt
+	} else if op == asteriskSymbol && isNumericType(lt) {
+		l, r, lt, rt = r, l, rt, lt
+	}
+
+	switch {
+	case lt == DateType && isIntegerType(rt):
+		days, err := integerArithmetic(PlusSymbol, l.AsInt64(), sign*r.AsInt64())
+		if err != nil || days < math.MinInt32 || days > math.MaxInt32 {
+			return MemoryCell{}, ErrDatetimeOutOfRange
+		}
+
+		return encodeDate(time.Unix(days*secondsPerDay, 0).UTC())
+	case lt == DateType && rt == DateType:
+		cell, _, _, err := intToMemoryCell(l.AsInt64()-r.AsInt64(), IntType)
+		return cell, err
+	case lt == TimeType && rt == IntervalType:
+		return encodeTime(l.AsInt64() + sign*(r.AsInterval().Microseconds%microsPerDay)), nil
+	case lt == TimeType && rt == TimeType:
+		return encodeInterval(Interval{Microseconds: l.AsInt64() - r.AsInt64()}), nil
+	case isDatetimeType(lt) && rt == IntervalType:
+		t, err := addInterval(l.AsTime(), r.AsInterval(), sign)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		// Dates are moved as timestamps at midnight
+		if lt == DateType {
+			lt = TimestampType
+		}
+
+		return encodeTimestamp(t, lt)
+	case isDatetimeType(lt) && isDatetimeType(rt):
+		diff, err := integerArithmetic(MinusSymbol, l.AsInt64(), r.AsInt64())
+		if err != nil {
+			return MemoryCell{}, ErrDatetimeOutOfRange
+		}
+
+		return encodeInterval(Interval{Days: int32(diff / microsPerDay), Microseconds: diff % microsPerDay}), nil
+	case lt == IntervalType && rt == IntervalType:
+		iv, err := addIntervals(l.AsInterval(), r.AsInterval(), sign)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		return encodeInterval(iv), nil
+	case lt == IntervalType && isNumericType(rt):
+		f, err := castNumber(r, rt, DoubleType)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		factor := f.AsFloat64()
+		if op == SlashSymbol {
+			if factor == 0 {
+				return MemoryCell{}, ErrDivisionByZero
+			}
+
+			factor = 1 / factor
+		}
+
+		iv, err := scaleInterval(l.AsInterval(), factor)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		return encodeInterval(iv), nil
+	}
+
+	return MemoryCell{}, ErrInvalidOperands
+}
+
+// dateTrunc truncates t to the precision of field, as in
+// date_trunc('month', ts)
+func dateTrunc(field string, t time.Time) (time.Time, error) {
+	year, month, day := t.Date()
+	startOfYear := func(y int) time.Time {
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	switch field {
+	case "microseconds":
+		return t, nil
+	case "milliseconds":
+		return t.Truncate(time.Millisecond), nil
+	case "second":
+		return t.Truncate(time.Second), nil
+	case "minute":
+		return t.Truncate(time.Minute), nil
+	case "hour":
+		return t.Truncate(time.Hour), nil
+	case "day":
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+	case "week":
+		// Weeks start on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, time.UTC), nil
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), nil
+	case "quarter":
+		return time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, time.UTC), nil
+	case "year":
+		return startOfYear(year), nil
+	case "decade":
+		return startOfYear(floorDiv(year, 10) * 10), nil
+	case "century":
+		// The 21st century starts in 2001
+		return startOfYear(floorDiv(year-1, 100)*100 + 1), nil
+	case "millennium":
+		return startOfYear(floorDiv(year-1, 1000)*1000 + 1), nil
+	}
+
+	return time.Time{}, ErrInvalidDatetimeUnit
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+
+	return q
+}
+
+// extractField returns a field of a temporal value as a numeric, as in
+// EXTRACT(year FROM ts). Seconds keep their fraction.
+func extractField(field string, value MemoryCell, typ ColumnType) (MemoryCell, error) {
+	ratio := func(num, denom int64, scale int) (MemoryCell, error) {
+		return numericCell(formatNumeric(big.NewRat(num, denom), scale)), nil
+	}
+
+	integer := func(i int) (MemoryCell, error) {
+		return numericCell(strconv.Itoa(i)), nil
+	}
+
+	if typ == IntervalType {
+		iv := value.AsInterval()
+		switch field {
+		case "microseconds":
+			return ratio(iv.Microseconds%microsPerMinute, 1, 0)
+		case "milliseconds":
+			return ratio(iv.Microseconds%microsPerMinute, 1000, 3)
+		case "second":
+			return ratio(iv.Microseconds%microsPerMinute, microsPerSecond, 6)
+		case "minute":
+			return ratio(iv.Microseconds%microsPerHour/microsPerMinute, 1, 0)
+		case "hour":
+			return ratio(iv.Microseconds/microsPerHour, 1, 0)
+		case "day":
+			return integer(int(iv.Days))
+		case "month":
+			return integer(int(iv.Months % 12))
+		case "year":
+			return integer(int(iv.Months / 12))
+		case "epoch":
+			epoch := new(big.Rat).SetFrac(iv.totalMicroseconds(), big.NewInt(microsPerSecond))
+			return numericCell(formatNumeric(epoch, 6)), nil
+		}
+
+		return MemoryCell{}, ErrInvalidDatetimeUnit
+	}
+
+	t := value.AsTime()
+	micros := int64(t.Second())*microsPerSecond + int64(t.Nanosecond()/1000)
+	switch field {
+	case "microseconds":
+		return ratio(micros, 1, 0)
+	case "milliseconds":
+		return ratio(micros, 1000, 3)
+	case "second":
+		return ratio(micros, microsPerSecond, 6)
+	case "minute":
+		return integer(t.Minute())
+	case "hour":
+		return integer(t.Hour())
+	case "epoch":
+		if typ == TimeType {
+			return ratio(value.AsInt64(), microsPerSecond, 6)
+		} else if typ == DateType {
+			return ratio(t.Unix(), 1, 0)
+		}
+
+		return ratio(t.UnixMicro(), microsPerSecond, 6)
+	}
+
+	// The remaining fields are parts of the date
+	if typ == TimeType {
+		return MemoryCell{}, ErrInvalidDatetimeUnit
+	}
+
+	_, week := t.ISOWeek()
+	switch field {
+	case "day":
+		return integer(t.Day())
+	case "dow":
+		return integer(int(t.Weekday()))
+	case "isodow":
+		return integer((int(t.Weekday())+6)%7 + 1)
+	case "doy":
+		return integer(t.YearDay())
+	case "week":
+		return integer(week)
+	case "month":
+		return integer(int(t.Month()))
+	case "quarter":
+		return integer((int(t.Month())-1)/3 + 1)
+	case "year":
+		return integer(t.Year())
+	case "decade":
+		return integer(floorDiv(t.Year(), 10))
+	case "century":
+		return integer(floorDiv(t.Year()-1, 100) + 1)
+	case "millennium":
+		return integer(floorDiv(t.Year()-1, 1000) + 1)
+	}
+
+	return MemoryCell{}, ErrInvalidDatetimeUnit
+}
@@ -1,37 +1,111 @@
 package pck
 
-import (
-	"fmt"
-)
+import "time"
 
 func (mb *MemoryBackend) CreateTable(crt *CreateTableStatement) error {
+	mb.statementTime = time.Now()
+
+	if _, ok := mb.tables[crt.name.value]; ok {
+		if crt.ifNotExists {
+			return nil
+		}
+
+		return ErrTableAlreadyExists
+	}
+
 	t := table{}
-	mb.tables[crt.name.value] = &t
-	if crt.cols == nil {
+	if crt.using != nil {
+		switch crt.using.value {
+		case heapAccessMethod:
+		case columnarAccessMethod:
+			// A table without columns has nothing to store column by column
+			t.columnar = crt.cols != nil && len(*crt.cols) > 0
+		default:
+			return ErrAccessMethodDoesNotExist
+		}
+	}
 
+	if crt.cols == nil {
+		mb.tables[crt.name.value] = &t
 		return nil
 	}
 
-	for _, col := range *crt.cols {
+	hasPrimaryKey := false
+	for i, col := range *crt.cols {
+		if _, err := t.columnIndex(col.name.value); err == nil {
+			return ErrColumnAlreadyExists
+		}
+
 		t.columns = append(t.columns, col.name.value)
 
-		var dt ColumnType
-		switch col.datatype.value {
-		case "int":
-			dt = IntType
-		case "text":
-			dt = TextType
-		default:
+		dt, ok := columnTypeFromName(col.datatype.value)
+		if !ok {
 			return ErrInvalidDatatype
 		}
 
+		tm := numericTypmod{}
+		if col.precision != nil {
+			if dt != NumericType {
+				return ErrInvalidTypeModifier
+			}
+
+			var err error
+			tm, err = newNumericTypmod(col.precision, col.scale)
+			if err != nil {
+				return err
+			}
+		}
+
 		t.columnTypes = append(t.columnTypes, dt)
+		t.typmods = append(t.typmods, tm)
+		t.constraints = append(t.constraints, columnConstraints{
+			notNull:      col.notNull || col.primaryKey,
+			defaultValue: col.defaultValue,
+		})
+
+		if col.defaultValue != nil {
+			if err := mb.checkDefault(&t, i, *col.defaultValue); err != nil {
+				return err
+			}
+		}
+
+		// Primary keys and unique columns are enforced by an index,
+		// named the way PostgreSQL names them
+		var idx *index
+		if col.primaryKey {
+			if hasPrimaryKey {
+				return ErrPrimaryKeyAlreadyExists
+			}
+
+			hasPrimaryKey = true
+			idx = newIndex(crt.name.value+"_pkey", uint(i), dt, true)
+		} else if col.unique {
+			idx = newIndex(crt.name.value+"_"+col.name.value+"_key", uint(i), dt, true)
+		}
+
+		if idx != nil {
+			idx.constraint = true
+			t.indexes = append(t.indexes, idx)
+		}
 	}
 
+	for _, idx := range t.indexes {
+		if mb.indexExists(idx.name) {
+			return ErrIndexAlreadyExists
+		}
+	}
+
+	if t.columnar {
+		t.columnData = make([][]MemoryCell, len(t.columns))
+	}
+
+	mb.tables[crt.name.value] = &t
 	return nil
 }
 
 func (mb *MemoryBackend) Insert(inst *InsertStatement) error {
+	mb.statementTime = time.Now()
+
 	t, ok := mb.tables[inst.table.value]
 	if !ok {
 		return ErrTableDoesNotExist
@@ -41,39 +115,257 @@ func (mb *MemoryBackend) Insert(inst *InsertStatement) error {
 		return nil
 	}
 
-	row := []MemoryCell{}
+	// Map each value onto the column it is inserted into
+	targets := []int{}
+	if inst.columns == nil {
+		for i := range t.columns {
+			targets = append(targets, i)
+		}
+	} else {
+		assigned := make([]bool, len(t.columns))
+		for _, col := range *inst.columns {
+			target, err := t.columnIndex(col.value)
+			if err != nil {
+				return err
+			}
+
+			if assigned[target] {
+				return ErrColumnAlreadyExists
+			}
 
-	if len(*inst.values) != len(t.columns) {
+			assigned[target] = true
+			targets = append(targets, target)
+		}
+	}
+
+	if len(*inst.values) != len(targets) {
 		return ErrMissingValues
 	}
 
-	for _, value := range *inst.values {
-		if value.kind != literalKind {
-			fmt.Println("Skipping non-literal.")
-			continue
+	values := make([]*expression, len(t.columns))
+	for i, value := range *inst.values {
+		values[targets[i]] = value
+	}
+
+	row := []MemoryCell{}
+	empty := mb.scope(&table{}, nil)
+	for i, value := range values {
+		if value == nil {
+			value = t.constraints[i].defaultValue
+		}
+
+		// A column with no value and no default is NULL
+		if value == nil {
+			value = &expression{
+				literal: &Token{kind: nullKind, value: string(NullKeyword)},
+				kind:    literalKind,
+			}
 		}
 
-		emptyTable := &table{}
-		value, _, _, err := emptyTable.evaluateCell(0, *value)
+		cell, _, typ, err := empty.evaluateCell(0, *value)
 		if err != nil {
 			return err
 		}
 
-		row = append(row, value)
+		cell, err = t.coerceToColumn(i, *value, cell, typ)
+		if err != nil {
+			return err
+		}
+
+		row = append(row, cell)
+	}
+
+	for _, idx := range t.indexes {
+		if err := idx.validate(row[idx.column]); err != nil {
+			return err
+		}
+	}
+
+	t.appendRow(row)
+	for _, idx := range t.indexes {
+		if err := idx.addRow(t, t.rowCount()-1); err != nil {
+			return err
+		}
 	}
 
-	t.rows = append(t.rows, row)
 	return nil
 }
 
+func (mb *MemoryBackend) Update(updt *UpdateStatement) (uint, error) {
+	mb.statementTime = time.Now()
+
+	t, ok := mb.tables[updt.table.value]
+	if !ok {
+		return 0, ErrTableDoesNotExist
+	}
+
+	// Columns can be qualified by the table name in SET values and WHERE
+	sc := mb.scope(t.qualified(updt.table.value), nil)
+	targets := []int{}
+	values := []compiledExpression{}
+	for _, assignment := range *updt.set {
+		target, err := t.columnIndex(assignment.column.value)
+		if err != nil {
+			return 0, err
+		}
+
+		value, err := sc.compileExpression(*assignment.value)
+		if err != nil {
+			return 0, err
+		}
+
+		targets = append(targets, target)
+		values = append(values, value)
+	}
+
+	matches, err := sc.filterRows(updt.where, -1)
+	if err != nil {
+		return 0, err
+	}
+
+	// Every SET expression sees the row as it was before the update, so
+	// the new rows are built aside and only written once all succeeded
+	updates := []rowUpdate{}
+	for _, i := range matches {
+		before := t.row(i)
+		after := append([]MemoryCell{}, before...)
+		for j, assignment := range *updt.set {
+			value, err := values[j].eval(i)
+			if err != nil {
+				return 0, err
+			}
+
+			value, err = t.coerceToColumn(targets[j], *assignment.value, value, values[j].typ)
+			if err != nil {
+				return 0, err
+			}
+
+			after[targets[j]] = value
+		}
+
+		updates = append(updates, rowUpdate{i, before, after})
+	}
+
+	if err := t.updateIndexes(updates); err != nil {
+		return 0, err
+	}
+
+	for _, u := range updates {
+		t.replaceRow(u.rowIndex, u.after)
+	}
+
+	return uint(len(updates)), nil
+}
+
+func (mb *MemoryBackend) Delete(dlt *DeleteStatement) (uint, error) {
+	mb.statementTime = time.Now()
+
+	t, ok := mb.tables[dlt.table.value]
+	if !ok {
+		return 0, ErrTableDoesNotExist
+	}
+
+	// Without a filter every row goes
+	matches, err := mb.scope(t.qualified(dlt.table.value), nil).filterRows(dlt.where, -1)
+	if err != nil {
+		return 0, err
+	}
+
+	kept := []uint{}
+	deleted := matches
+	for i := uint(0); i < t.rowCount(); i++ {
+		if len(matches) > 0 && matches[0] == i {
+			matches = matches[1:]
+			continue
+		}
+
+		kept = append(kept, i)
+	}
+
+	for _, idx := range t.indexes {
+		idx.deleteRows(t, deleted)
+	}
+
+	t.replaceRows(t.subset(kept))
+	return uint(len(deleted)), nil
+}
+
+func (mb *MemoryBackend) CreateIndex(ci *CreateIndexStatement) error {
+	t, ok := mb.tables[ci.table.value]
+	if !ok {
+		return ErrTableDoesNotExist
+	}
+
+	if mb.indexExists(ci.name.value) {
+		return ErrIndexAlreadyExists
+	}
+
+	column, err := t.columnIndex(ci.column.value)
+	if err != nil {
+		return err
+	}
+
+	idx := newIndex(ci.name.value, uint(column), t.columnTypes[column], ci.unique)
+
+	for i := uint(0); i < t.rowCount(); i++ {
+		if err := idx.addRow(t, i); err != nil {
+			return err
+		}
+	}
+
+	t.indexes = append(t.indexes, idx)
+	return nil
+}
+
+func (mb *MemoryBackend) DropTable(dt *DropTableStatement) error {
+	if _, ok := mb.tables[dt.name.value]; !ok {
+		if dt.ifExists {
+			return nil
+		}
+
+		return ErrTableDoesNotExist
+	}
+
+	delete(mb.tables, dt.name.value)
+	return nil
+}
+
+func (mb *MemoryBackend) DropIndex(di *DropIndexStatement) error {
+	for _, t := range mb.tables {
+		for i, idx := range t.indexes {
+			if idx.name == di.name.value {
+				if idx.constraint {
+					return ErrIndexRequiredByConstraint
+				}
+
+				t.indexes = append(t.indexes[:i], t.indexes[i+1:]...)
+				return nil
+			}
+		}
+	}
+
+	if di.ifExists {
+		return nil
+	}
+
+	return ErrIndexDoesNotExist
+}
+
 func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
+	mb.statementTime = time.Now()
+	return mb.query(slct, nil)
+}
+
+// query runs a SELECT, which is a subquery evaluated for a row of an
+// enclosing query when outer is set
+func (mb *MemoryBackend) query(slct *SelectStatement, outer *outerRow) (*Results, error) {
 	t := &table{}
 
 	if slct.from != nil {
-		var ok bool
-		t, ok = mb.tables[slct.from.value]
-		if !ok {
-			return nil, ErrTableDoesNotExist
+		var err error
+		t, err = mb.fromTable(*slct.from, outer)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -81,11 +373,34 @@ func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
 		return &Results{}, nil
 	}
 
-	results := [][]Cell{}
-	columns := []ResultColumn{}
+	// Narrow the scan down to the rows an index can find for the filter
+	if slct.where != nil {
+		for _, idx := range t.indexes {
+			if subset, ok := idx.newTableFromSubset(t, *slct.where); ok {
+				t = subset
+				break
+			}
+		}
+	}
 
-	if slct.from == nil {
-		t := &table{}
+	var limit, offset uint
+	var err error
+	if slct.limit != nil {
+		limit, err = mb.scope(t, outer).evaluateBound(*slct.limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if slct.offset != nil {
+		offset, err = mb.scope(t, outer).evaluateBound(*slct.offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Without a FROM the select items are evaluated once
+	if slct.from == nil && !outer.describing() {
 		t.rows = [][]MemoryCell{{}}
 	}
 
@@ -95,10 +410,16 @@ func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
 		if item.Asterisk {
 			newItems := []*SelectItem{}
 			for j := 0; j < len(t.columns); j++ {
+				if t.isQualifiedOnly(j) {
+					continue
+				}
+
+				// Columns of joined tables and subqueries may share a
+				// name or have none, so refer to them by position
 				newSelectItem := &SelectItem{
 					Exp: &expression{
 						literal: &Token{
-							value: t.columns[j],
+							value: columnAt(j),
 							kind:  identifierKind,
 							loc:   location{0, uint(len("SELECT") + 1)},
 						},
@@ -116,54 +437,94 @@ func (mb *MemoryBackend) Select(slct *SelectStatement) (*Results, error) {
 		}
 	}
 
-	rowIndex := -1
-	for i := range t.rows {
-		result := []Cell{}
-		isFirstRow := len(results) == 0
+	// Aggregate queries select from a table holding a row per group. The
+	// filter applies before the rows are grouped and HAVING filters the
+	// groups.
+	where, orderBy := slct.where, slct.orderBy
+	var names []string
+	sc := mb.scope(t, outer)
+	if sc.isAggregateQuery(slct, finalItems) {
+		grouped, err := sc.group(slct, finalItems)
+		if err != nil {
+			return nil, err
+		}
 
-		if slct.where != nil {
-			val, _, _, err := t.evaluateCell(uint(i), *slct.where)
-			if err != nil {
-				return nil, err
-			}
+		t, finalItems, names = grouped.table, grouped.items, grouped.names
+		where, orderBy = grouped.having, grouped.orderBy
+		sc = mb.scope(t, outer)
+		sc.grouping = grouped.grouping
+	}
 
-			if !val.AsBool() {
-				continue
-			}
+	// The result columns follow from the select list and the schema
+	// alone, so they are known even when no row matches
+	items := []compiledExpression{}
+	columns := []ResultColumn{}
+	for i, item := range finalItems {
+		c, err := sc.compileExpression(*item.Exp)
+		if err != nil {
+			return nil, err
+		}
+
+		name := c.name
+		if item.As != nil {
+			name = item.As.value
+		} else if names != nil {
+			name = names[i]
+		}
+
+		items = append(items, c)
+		columns = append(columns, ResultColumn{
+			Type: c.typ,
+			Name: name,
+		})
+	}
+
+	// Find the rows that pass the filter. Without an ORDER BY the scan
+	// stops as soon as the requested page is full.
+	max := -1
+	if orderBy == nil && slct.limit != nil {
+		max = int(offset + limit)
+	}
+
+	matches, err := sc.filterRows(where, max)
+	if err != nil {
+		return nil, err
+	}
+
+	if orderBy != nil {
+		matches, err = sc.sortRows(matches, *orderBy, finalItems)
+		if err != nil {
+			return nil, err
 		}
+	}
+
+	if offset >= uint(len(matches)) {
+		matches = nil
+	} else {
+		matches = matches[offset:]
+	}
 
-		rowIndex++
+	if slct.limit != nil && uint(len(matches)) > limit {
+		matches = matches[:limit]
+	}
 
-		for _, col := range finalItems {
-			value, columnName, columnType, err := t.evaluateCell(uint(i), *col.Exp)
+	results := [][]Cell{}
+	for _, i := range matches {
+		result := make([]Cell, 0, len(items))
+		for _, col := range items {
+			value, err := col.eval(i)
 			if err != nil {
 				return nil, err
 			}
 
-			if isFirstRow {
-				columns = append(columns, ResultColumn{
-					Type: columnType,
-					Name: columnName,
-				})
-			}
-
 			result = append(result, value)
 		}
 
 		results = append(results, result)
 	}
 
-	var resultColumns []ResultColumn
-
-	for _, col := range columns {
-		resultColumns = append(resultColumns, ResultColumn{
-			Type: col.Type,
-			Name: col.Name,
-		})
-	}
-
 	return &Results{
-		Columns: resultColumns,
+		Columns: columns,
 		Rows:    results,
 	}, nil
 }
\ No newline at end of file
@@ -0,0 +1,427 @@
+package pck
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// argument describes an argument of a function call to the function
+// resolving it
+type argument struct {
+	typ ColumnType
+	// NULL and string literals have no type of their own, they take the
+	// type the function expects
+	null    bool
+	untyped bool
+}
+
+// accepts reports whether the argument can be passed where a value of
+// typ is expected, numbers and dates widen the way operators widen them
+func (a argument) accepts(typ ColumnType) bool {
+	if a.null || a.untyped || a.typ == typ {
+		return true
+	}
+
+	common, ok := commonType(a.typ, typ)
+	return ok && common == typ
+}
+
+// scalarFunction is a function computing a value from the arguments of a
+// single row
+type scalarFunction struct {
+	// resolve checks the arguments of a call, it returns the types they
+	// are converted to before the call and the type of the result
+	resolve resolver
+	// call computes the result from arguments of the resolved types
+	call func(args []MemoryCell, types []ColumnType, result ColumnType) (MemoryCell, error)
+	// strict functions return NULL without being called when any
+	// argument is NULL
+	strict bool
+	// current is called instead of call by functions such as now(),
+	// whose value is that of the statement rather than of a call, so
+	// every call in a statement returns the same one
+	current func(statementTime time.Time) (MemoryCell, error)
+	// lazy is called instead of call by functions such as coalesce which
+	// may not need all of their n arguments, arg evaluates the argument
+	// at index i
+	lazy func(n int, arg func(i int) (MemoryCell, error)) (MemoryCell, error)
+}
+
+type resolver func(args []argument) ([]ColumnType, ColumnType, bool)
+
+// signature lists the types of the parameters of a function and the type
+// of its result
+type signature struct {
+	result ColumnType
+	params []ColumnType
+}
+
+func (s signature) matches(args []argument, exact bool) bool {
+	if len(args) != len(s.params) {
+		return false
+	}
+
+	for i, arg := range args {
+		if exact && !arg.null && !arg.untyped && arg.typ != s.params[i] {
+			return false
+		}
+
+		if !arg.accepts(s.params[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// overloads resolves calls with the first signature taking the arguments
+// as they are, or else the first one they can be converted to
+func overloads(signatures ...signature) resolver {
+	return func(args []argument) ([]ColumnType, ColumnType, bool) {
+		for _, exact := range []bool{true, false} {
+			for _, s := range signatures {
+				if s.matches(args, exact) {
+					return s.params, s.result, true
+				}
+			}
+		}
+
+		return nil, 0, false
+	}
+}
+
+func fixed(result ColumnType, params ...ColumnType) resolver {
+	return overloads(signature{result, params})
+}
+
+// commonArgumentType returns the type all the arguments are converted to
+// by functions taking values of any type, such as coalesce. Arguments
+// without a type are read as text when none has one.
+func commonArgumentType(args []argument) (ColumnType, bool) {
+	typ, typed := TextType, false
+	for _, arg := range args {
+		if arg.null || arg.untyped {
+			continue
+		}
+
+		if !typed {
+			typ, typed = arg.typ, true
+			continue
+		}
+
+		if arg.typ == typ {
+			continue
+		}
+
+		common, ok := commonType(typ, arg.typ)
+		if !ok {
+			return 0, false
+		}
+
+		typ = common
+	}
+
+	return typ, true
+}
+
+// sameType resolves calls converting at least min arguments to their
+// common type, which is the type of the result
+func sameType(min int, comparable bool) resolver {
+	return func(args []argument) ([]ColumnType, ColumnType, bool) {
+		if len(args) < min {
+			return nil, 0, false
+		}
+
+		typ, ok := commonArgumentType(args)
+		if !ok || (comparable && !comparableTypes(typ, typ)) {
+			return nil, 0, false
+		}
+
+		types := make([]ColumnType, len(args))
+		for i := range types {
+			types[i] = typ
+		}
+
+		return types, typ, true
+	}
+}
+
+// datetimeArgument resolves the type of a point in time taken by
+// date_trunc, NULL and string literals are read as timestamps
+func datetimeArgument(arg argument) (ColumnType, bool) {
+	switch {
+	case arg.null || arg.untyped:
+		return TimestampType, true
+	case isDatetimeType(arg.typ):
+		return arg.typ, true
+	default:
+		return 0, false
+	}
+}
+
+// extreme returns greatest when sign is 1 and least when it is -1, NULL
+// arguments are ignored
+func extreme(sign int) func([]MemoryCell, []ColumnType, ColumnType) (MemoryCell, error) {
+	return func(args []MemoryCell, _ []ColumnType, typ ColumnType) (MemoryCell, error) {
+		result := MemoryCell{}
+		for _, arg := range args {
+			if !arg.IsNull() && (result.IsNull() || compareCells(arg, result, typ)*sign > 0) {
+				result = arg
+			}
+		}
+
+		return result, nil
+	}
+}
+
+// roundNumeric rounds r to scale fractional digits, a negative scale
+// rounds to tens, hundreds and so on
+func roundNumeric(r *big.Rat, scale int64) MemoryCell {
+	if scale >= 0 {
+		return numericCell(formatNumeric(r, int(scale)))
+	}
+
+	factor := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(-scale), nil))
+	rounded := numericCell(formatNumeric(new(big.Rat).Quo(r, factor), 0))
+	return numericCell(formatNumeric(new(big.Rat).Mul(rounded.AsNumeric(), factor), 0))
+}
+
+var scalarFunctions = map[string]scalarFunction{
+	"lower": {
+		resolve: fixed(TextType, TextType),
+		call: func(args []MemoryCell, _ []ColumnType, _ ColumnType) (MemoryCell, error) {
+			return textCell(strings.ToLower(args[0].AsText())), nil
+		},
+		strict: true,
+	},
+	"upper": {
+		resolve: fixed(TextType, TextType),
+		call: func(args []MemoryCell, _ []ColumnType, _ ColumnType) (MemoryCell, error) {
+			return textCell(strings.ToUpper(args[0].AsText())), nil
+		},
+		strict: true,
+	},
+	"length": {
+		resolve: fixed(IntType, TextType),
+		call: func(args []MemoryCell, _ []ColumnType, _ ColumnType) (MemoryCell, error) {
+			return encodeInt(int64(utf8.RuneCountInString(args[0].AsText())), IntType), nil
+		},
+		strict: true,
+	},
+	// substr counts characters from 1, positions before the start of the
+	// string still use up the length
+	"substr": {
+		resolve: overloads(
+			signature{TextType, []ColumnType{TextType, IntType}},
+			signature{TextType, []ColumnType{TextType, IntType, IntType}},
+		),
+		call: func(args []MemoryCell, _ []ColumnType, _ ColumnType) (MemoryCell, error) {
+			runes := []rune(args[0].AsText())
+			start, end := args[1].AsInt64(), int64(len(runes))+1
+			if len(args) == 3 {
+				count := args[2].AsInt64()
+				if count < 0 {
+					return MemoryCell{}, ErrNegativeSubstringLength
+				}
+
+				if start+count < end {
+					end = start + count
+				}
+			}
+
+			if start < 1 {
+				start = 1
+			}
+
+			if start >= end {
+				return textCell(""), nil
+			}
+
+			return textCell(string(runes[start-1 : end-1])), nil
+		},
+		strict: true,
+	},
+	// trim removes spaces, or the given characters, from both ends
+	"trim": {
+		resolve: overloads(
+			signature{TextType, []ColumnType{TextType}},
+			signature{TextType, []ColumnType{TextType, TextType}},
+		),
+		call: func(args []MemoryCell, _ []ColumnType, _ ColumnType) (MemoryCell, error) {
+			characters := " "
+			if len(args) == 2 {
+				characters = args[1].AsText()
+			}
+
+			return textCell(strings.Trim(args[0].AsText(), characters)), nil
+		},
+		strict: true,
+	},
+	"abs": {
+		resolve: overloads(
+			signature{SmallIntType, []ColumnType{SmallIntType}},
+			signature{IntType, []ColumnType{IntType}},
+			signature{BigIntType, []ColumnType{BigIntType}},
+			signature{NumericType, []ColumnType{NumericType}},
+			signature{RealType, []ColumnType{RealType}},
+			signature{DoubleType, []ColumnType{DoubleType}},
+		),
+		call: func(args []MemoryCell, _ []ColumnType, typ ColumnType) (MemoryCell, error) {
+			switch {
+			case isIntegerType(typ):
+				i := args[0].AsInt64()
+				if i == math.MinInt64 {
+					return MemoryCell{}, ErrIntegerOutOfRange
+				}
+
+				if i < 0 {
+					i = -i
+				}
+
+				cell, _, _, err := intToMemoryCell(i, typ)
+				return cell, err
+			case typ == NumericType:
+				return numericCell(strings.TrimPrefix(args[0].AsText(), "-")), nil
+			default:
+				return encodeFloat(math.Abs(args[0].AsFloat64()), typ), nil
+			}
+		},
+		strict: true,
+	},
+	// round takes halves away from zero for numerics and to even for
+	// floats. Integers are rounded as double precision, the type
+	// PostgreSQL prefers for them.
+	"round": {
+		resolve: overloads(
+			signature{DoubleType, []ColumnType{DoubleType}},
+			signature{NumericType, []ColumnType{NumericType}},
+			signature{NumericType, []ColumnType{NumericType, IntType}},
+		),
+		call: func(args []MemoryCell, _ []ColumnType, typ ColumnType) (MemoryCell, error) {
+			if typ == DoubleType {
+				return encodeFloat(math.RoundToEven(args[0].AsFloat64()), DoubleType), nil
+			}
+
+			scale := int64(0)
+			if len(args) == 2 {
+				scale = args[1].AsInt64()
+				if scale > numericMaxPrecision || scale < -numericMaxPrecision {
+					return MemoryCell{}, ErrNumericFieldOverflow
+				}
+			}
+
+			return roundNumeric(args[0].AsNumeric(), scale), nil
+		},
+		strict: true,
+	},
+	// coalesce stops at the first argument that isn't NULL, the ones
+	// after it are not evaluated
+	"coalesce": {
+		resolve: sameType(1, false),
+		lazy: func(n int, arg func(int) (MemoryCell, error)) (MemoryCell, error) {
+			for i := 0; i < n; i++ {
+				value, err := arg(i)
+				if err != nil || !value.IsNull() {
+					return value, err
+				}
+			}
+
+			return MemoryCell{}, nil
+		},
+	},
+	"nullif": {
+		resolve: func(args []argument) ([]ColumnType, ColumnType, bool) {
+			if len(args) != 2 {
+				return nil, 0, false
+			}
+
+			return sameType(2, false)(args)
+		},
+		call: func(args []MemoryCell, _ []ColumnType, typ ColumnType) (MemoryCell, error) {
+			if !args[0].IsNull() && !args[1].IsNull() && cellsEqual(args[0], args[1], typ, typ) {
+				return MemoryCell{}, nil
+			}
+
+			return args[0], nil
+		},
+	},
+	"greatest": {
+		resolve: sameType(1, true),
+		call:    extreme(1),
+	},
+	"least": {
+		resolve: sameType(1, true),
+		call:    extreme(-1),
+	},
+	"now": {
+		resolve: fixed(TimestampTzType),
+		current: func(statementTime time.Time) (MemoryCell, error) {
+			return encodeTimestamp(statementTime.UTC().Truncate(time.Microsecond), TimestampTzType)
+		},
+	},
+	// date_trunc returns a timestamp for a date, like PostgreSQL which
+	// converts the date first
+	"date_trunc": {
+		resolve: func(args []argument) ([]ColumnType, ColumnType, bool) {
+			if len(args) != 2 || !args[0].accepts(TextType) {
+				return nil, 0, false
+			}
+
+			typ, ok := datetimeArgument(args[1])
+			if typ == DateType {
+				typ = TimestampType
+			}
+
+			return []ColumnType{TextType, typ}, typ, ok
+		},
+		call: func(args []MemoryCell, _ []ColumnType, typ ColumnType) (MemoryCell, error) {
+			truncated, err := dateTrunc(strings.ToLower(args[0].AsText()), args[1].AsTime())
+			if err != nil {
+				return MemoryCell{}, err
+			}
+
+			return encodeTimestamp(truncated, typ)
+		},
+		strict: true,
+	},
+	// EXTRACT returns a numeric and date_part a double precision
+	"extract": {
+		resolve: extractResolver(NumericType),
+		call:    extract,
+		strict:  true,
+	},
+	"date_part": {
+		resolve: extractResolver(DoubleType),
+		call:    extract,
+		strict:  true,
+	},
+}
+
+func extractResolver(result ColumnType) resolver {
+	return func(args []argument) ([]ColumnType, ColumnType, bool) {
+		if len(args) != 2 || !args[0].accepts(TextType) {
+			return nil, 0, false
+		}
+
+		typ := args[1].typ
+		if args[1].null || args[1].untyped {
+			typ = TimestampType
+		} else if !isTemporalType(typ) {
+			return nil, 0, false
+		}
+
+		return []ColumnType{TextType, typ}, result, true
+	}
+}
+
+func extract(args []MemoryCell, types []ColumnType, result ColumnType) (MemoryCell, error) {
+	value, err := extractField(strings.ToLower(args[0].AsText()), args[1], types[1])
+	if err != nil {
+		return MemoryCell{}, err
+	}
+
+	return castNumber(value, NumericType, result)
+}
@@ -1,148 +1,616 @@
 package pck
 
 import (
-	"bytes"
-	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"sort"
 	"strconv"
+	"strings"
 )
 
-func (mb *MemoryBackend) tokenToCell(t *Token) MemoryCell {
-	if t.kind == numericKind {
-		buf := new(bytes.Buffer)
-		i, err := strconv.Atoi(t.value)
-		if err != nil {
-			panic(err)
+func (mb *MemoryBackend) indexExists(name string) bool {
+	for _, t := range mb.tables {
+		for _, idx := range t.indexes {
+			if idx.name == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// columnTypeFromName maps the name of a type, as written in a column
+// definition or a typed literal, to the column type
+func columnTypeFromName(name string) (ColumnType, bool) {
+	switch name {
+	case "int", "integer":
+		return IntType, true
+	case "smallint":
+		return SmallIntType, true
+	case "bigint":
+		return BigIntType, true
+	case "real":
+		return RealType, true
+	case "double precision":
+		return DoubleType, true
+	case "numeric", "decimal":
+		return NumericType, true
+	case "text":
+		return TextType, true
+	case "boolean", "bool":
+		return BoolType, true
+	case "date":
+		return DateType, true
+	case "time":
+		return TimeType, true
+	case "timestamp":
+		return TimestampType, true
+	case "timestamp with time zone":
+		return TimestampTzType, true
+	case "interval":
+		return IntervalType, true
+	}
+
+	return 0, false
+}
+
+// columnAtPrefix starts the names columnAt gives, which like the group
+// columns of aggregate queries can't be written without quotes
+const columnAtPrefix = "?position"
+
+// columnAt names the column at index i of a table, which is how SELECT *
+// refers to columns whose names may be ambiguous or missing
+func columnAt(i int) string {
+	return columnAtPrefix + strconv.Itoa(i)
+}
+
+// columnIndex finds the column a name refers to. A name without a
+// qualifier must only match a single column of the FROM items.
+func (t *table) columnIndex(name string) (int, error) {
+	if position, ok := strings.CutPrefix(name, columnAtPrefix); ok {
+		if i, err := strconv.Atoi(position); err == nil && i >= 0 && i < len(t.columns) {
+			return i, nil
+		}
+
+		return 0, ErrColumnDoesNotExist
+	}
+
+	found := -1
+	for i, col := range t.columns {
+		if col != name || (t.qualifiedOnly != nil && t.qualifiedOnly[i]) {
+			continue
 		}
 
-		err = binary.Write(buf, binary.BigEndian, int32(i))
+		if found >= 0 {
+			return 0, ErrAmbiguousColumn
+		}
+
+		found = i
+	}
+
+	if found >= 0 {
+		return found, nil
+	}
+
+	qualifier, column, ok := strings.Cut(name, ".")
+	if !ok || t.qualifiers == nil {
+		return 0, ErrColumnDoesNotExist
+	}
+
+	for i, col := range t.columns {
+		if col == column && t.qualifiers[i] == qualifier {
+			return i, nil
+		}
+	}
+
+	return 0, ErrColumnDoesNotExist
+}
+
+// evaluateBound evaluates a LIMIT or OFFSET expression, which must be a
+// non-negative integer. It can't refer to the columns of sc.t.
+func (sc *scope) evaluateBound(exp expression) (uint, error) {
+	empty := &scope{t: &table{}, functions: sc.functions, mb: sc.mb, outer: sc.outer}
+	c, err := empty.compileExpression(exp)
+	if err != nil {
+		return 0, err
+	}
+
+	if !isIntegerType(c.typ) {
+		return 0, ErrInvalidLimit
+	}
+
+	// A query compiled only for its columns reads no rows anyway
+	if sc.outer.describing() {
+		return 0, nil
+	}
+
+	value, err := c.eval(0)
+	if err != nil {
+		return 0, err
+	}
+
+	if value.IsNull() || value.AsInt64() < 0 {
+		return 0, ErrInvalidLimit
+	}
+
+	return uint(value.AsInt64()), nil
+}
+
+// selectItemAt returns the select item at a position counted from 1,
+// which GROUP BY and ORDER BY keys can refer to
+func selectItemAt(position Token, items []*SelectItem) (*SelectItem, error) {
+	i, err := strconv.ParseUint(position.value, 10, 64)
+	if err != nil || i < 1 || i > uint64(len(items)) {
+		return nil, ErrInvalidSelectItem
+	}
+
+	return items[i-1], nil
+}
+
+// sortRows orders rows by the ORDER BY keys, rows with equal keys keep
+// their original order. A key naming a select item alias or giving its
+// position sorts by that item.
+func (sc *scope) sortRows(rowIndexes []uint, orderBy []*orderByItem, items []*SelectItem) ([]uint, error) {
+	exps := []expression{}
+	for _, ob := range orderBy {
+		exp := *ob.exp
+		if exp.kind == literalKind && exp.literal.kind == numericKind {
+			item, err := selectItemAt(*exp.literal, items)
+			if err != nil {
+				return nil, err
+			}
+
+			exps = append(exps, *item.Exp)
+			continue
+		}
+
+		for _, item := range items {
+			if item.As != nil && exp.kind == literalKind && exp.literal.kind == identifierKind && item.As.value == exp.literal.value {
+				exp = *item.Exp
+				break
+			}
+		}
+
+		exps = append(exps, exp)
+	}
+
+	type sortRow struct {
+		rowIndex uint
+		keys     []MemoryCell
+	}
+
+	keys := []compiledExpression{}
+	types := []ColumnType{}
+	for _, exp := range exps {
+		c, err := sc.compileExpression(exp)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		return MemoryCell(buf.Bytes())
+
+		keys = append(keys, c)
+		types = append(types, c.typ)
 	}
 
-	if t.kind == stringKind {
-		return MemoryCell(t.value)
+	rows := []sortRow{}
+	for _, rowIndex := range rowIndexes {
+		row := sortRow{rowIndex: rowIndex}
+		for _, c := range keys {
+			key, err := c.eval(rowIndex)
+			if err != nil {
+				return nil, err
+			}
+
+			row.keys = append(row.keys, key)
+		}
+
+		rows = append(rows, row)
 	}
 
-	return nil
+	sort.SliceStable(rows, func(a, b int) bool {
+		for j, ob := range orderBy {
+			ak, bk := rows[a].keys[j], rows[b].keys[j]
+			aNull, bNull := ak.IsNull(), bk.IsNull()
+			if aNull && bNull {
+				continue
+			} else if aNull {
+				return ob.nullsFirst
+			} else if bNull {
+				return !ob.nullsFirst
+			}
+
+			c := compareCells(ak, bk, types[j])
+			if ob.desc {
+				c = -c
+			}
+
+			if c != 0 {
+				return c < 0
+			}
+		}
+
+		return false
+	})
+
+	sorted := []uint{}
+	for _, row := range rows {
+		sorted = append(sorted, row.rowIndex)
+	}
+
+	return sorted, nil
+}
+
+// coerceCell converts a value so it can be stored in a column of type
+// to, following PostgreSQL's assignment casts. Untyped values are string
+// literals, which may also be parsed as the column type.
+func coerceCell(value MemoryCell, from, to ColumnType, untyped bool) (MemoryCell, error) {
+	if value.IsNull() || from == to {
+		return value, nil
+	}
+
+	switch to {
+	case TextType:
+		// Every type renders itself as text
+		return textCell(value.AsText()), nil
+	case SmallIntType, IntType, BigIntType:
+		if isNumericType(from) {
+			return castNumber(value, from, to)
+		}
+
+		if !untyped {
+			break
+		}
+
+		i, err := strconv.ParseInt(strings.TrimSpace(value.AsText()), 10, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return MemoryCell{}, ErrIntegerOutOfRange
+		} else if err != nil {
+			break
+		}
+
+		cell, _, _, err := intToMemoryCell(i, to)
+		return cell, err
+	case NumericType:
+		if isNumericType(from) {
+			return castNumber(value, from, to)
+		}
+
+		if !untyped {
+			break
+		}
+
+		cell, typ, err := parseNumericLiteral(strings.TrimSpace(value.AsText()))
+		if errors.Is(err, ErrNumericFieldOverflow) {
+			return MemoryCell{}, err
+		} else if err != nil {
+			break
+		}
+
+		return castNumber(cell, typ, to)
+	case RealType, DoubleType:
+		if isNumericType(from) {
+			return castNumber(value, from, to)
+		}
+
+		if !untyped {
+			break
+		}
+
+		f, err := strconv.ParseFloat(strings.TrimSpace(value.AsText()), 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return MemoryCell{}, ErrFloatOutOfRange
+		} else if err != nil {
+			break
+		}
+
+		cell, _, _, err := floatToMemoryCell(f, to)
+		return cell, err
+	case DateType, TimeType, TimestampType, TimestampTzType, IntervalType:
+		if isDatetimeType(from) && isDatetimeType(to) {
+			return castDatetime(value, from, to)
+		}
+
+		if to == TimeType && (from == TimestampType || from == TimestampTzType) {
+			return encodeTime(value.AsInt64()), nil
+		}
+
+		if !untyped {
+			break
+		}
+
+		return parseTemporal(strings.TrimSpace(value.AsText()), to)
+	case BoolType:
+		if !untyped {
+			break
+		}
+
+		switch strings.ToLower(strings.TrimSpace(value.AsText())) {
+		case "t", "true", "y", "yes", "on", "1":
+			return trueMemoryCell, nil
+		case "f", "false", "n", "no", "off", "0":
+			return falseMemoryCell, nil
+		}
+	}
+
+	return MemoryCell{}, ErrInvalidDatatype
+}
+
+// coerceToColumn prepares a value for storage in column i of t
+func (t *table) coerceToColumn(i int, exp expression, value MemoryCell, typ ColumnType) (MemoryCell, error) {
+	untyped := isStringLiteral(exp)
+	cell, err := coerceCell(value, typ, t.columnTypes[i], untyped)
+	if errors.Is(err, ErrInvalidDatatype) {
+		return MemoryCell{}, &DatatypeMismatchError{
+			Column:   t.columns[i],
+			Expected: t.columnTypes[i],
+			Actual:   typ,
+		}
+	} else if err != nil {
+		return MemoryCell{}, err
+	}
+
+	if t.columnTypes[i] == NumericType {
+		if cell, err = t.typmods[i].apply(cell); err != nil {
+			return MemoryCell{}, err
+		}
+	}
+
+	if cell.IsNull() && t.constraints[i].notNull {
+		return MemoryCell{}, ErrViolatesNotNullConstraint
+	}
+
+	return cell, nil
+}
+
+// assignableType reports whether coerceCell can store any value of type
+// from in a column of type to
+func assignableType(from, to ColumnType) bool {
+	switch {
+	case from == to || to == TextType:
+		return true
+	case isNumericType(to):
+		return isNumericType(from)
+	case isDatetimeType(to):
+		return isDatetimeType(from)
+	case to == TimeType:
+		return from == TimestampType || from == TimestampTzType
+	}
+
+	return false
+}
+
+// checkDefault compiles the default of column i of t and checks that it
+// can be stored in the column, so a bad default fails CREATE TABLE
+// instead of every INSERT relying on it
+func (mb *MemoryBackend) checkDefault(t *table, i int, exp expression) error {
+	def, err := mb.scope(&table{}, nil).compileExpression(exp)
+	if err != nil {
+		return err
+	}
+
+	// Other defaults, like now(), are evaluated on every insert
+	if !def.constant {
+		if !assignableType(def.typ, t.columnTypes[i]) {
+			return &DatatypeMismatchError{
+				Column:   t.columns[i],
+				Expected: t.columnTypes[i],
+				Actual:   def.typ,
+			}
+		}
+
+		return nil
+	}
+
+	value, err := def.eval(0)
+	if err != nil {
+		return err
+	}
+
+	// NOT NULL is only checked once a NULL default is used
+	if value.IsNull() {
+		return nil
+	}
+
+	_, err = t.coerceToColumn(i, exp, value, def.typ)
+	return err
+}
+
+func isNullLiteral(exp expression) bool {
+	return exp.kind == literalKind && exp.literal.kind == nullKind
+}
+
+func isStringLiteral(exp expression) bool {
+	return exp.kind == literalKind && exp.literal.kind == stringKind
 }
 
 func literalToMemoryCell(t *Token) MemoryCell {
 	if t.kind == numericKind {
-		buf := new(bytes.Buffer)
-		i, err := strconv.Atoi(t.value)
+		cell, _, err := parseNumericLiteral(t.value)
 		if err != nil {
 			fmt.Printf("Corrupted data [%s]: %s\n", t.value, err)
-			return MemoryCell(nil)
+			return MemoryCell{}
 		}
 
-		// TODO: handle bigint
-		err = binary.Write(buf, binary.BigEndian, int32(i))
-		if err != nil {
-			fmt.Printf("Corrupted data [%s]: %s\n", string(buf.Bytes()), err)
-			return MemoryCell(nil)
-		}
-		// type conversion MemoryCell alias for []byte
-		return MemoryCell(buf.Bytes())
+		return cell
 	}
 
 	if t.kind == stringKind {
-		return MemoryCell(t.value)
+		return textCell(t.value)
 	}
 
 	if t.kind == boolKind {
 		if t.value == "true" {
-			return MemoryCell([]byte{1})
+			return trueMemoryCell
 		} else {
-			return MemoryCell(nil)
+			return falseMemoryCell
 		}
 	}
 
-	return nil
+	// NULL
+	return MemoryCell{}
 }
 
-func (t *table) evaluateLiteralCell(rowIndex uint, exp expression) (MemoryCell, string, ColumnType, error) {
-	if exp.kind != literalKind {
-		return nil, "", 0, ErrInvalidCell
+// nullOperandTypes gives an untyped NULL operand the type of the other
+// operand
+func nullOperandTypes(bexp binaryExpression, lt, rt ColumnType) (ColumnType, ColumnType) {
+	if isNullLiteral(bexp.a) {
+		lt = rt
 	}
 
-	lit := exp.literal
-	if lit.kind == identifierKind {
-		for i, tableCol := range t.columns {
-			if tableCol == lit.value {
-				return t.rows[rowIndex][i], tableCol, t.columnTypes[i], nil
-			}
-		}
-
-		return nil, "", 0, ErrColumnDoesNotExist
+	if isNullLiteral(bexp.b) {
+		rt = lt
 	}
 
-	columnType := IntType
-	if lit.kind == stringKind {
-		columnType = TextType
-	} else if lit.kind == boolKind {
-		columnType = BoolType
-	}
-
-	return literalToMemoryCell(lit), "?column?", columnType, nil
+	return lt, rt
 }
 
-func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, string, ColumnType, error) {
-	if exp.kind != binaryKind {
-		return nil, "", 0, ErrInvalidCell
+// untypedOperand reads an untyped string compared with a value of another
+// type as that type, as in created < '2024-01-01'
+func untypedOperand(op Token, exp expression, value MemoryCell, typ, other ColumnType) (MemoryCell, ColumnType, error) {
+	if op.kind != symbolKind || !isComparison(symbol(op.value)) || !isStringLiteral(exp) || other == TextType {
+		return value, typ, nil
 	}
 
-	bexp := exp.binary
-
-	l, _, lt, err := t.evaluateCell(rowIndex, bexp.a)
+	value, err := coerceCell(value, TextType, other, true)
 	if err != nil {
-		return nil, "", 0, err
+		return MemoryCell{}, 0, err
 	}
 
-	r, _, rt, err := t.evaluateCell(rowIndex, bexp.b)
-	if err != nil {
-		return nil, "", 0, err
+	return value, other, nil
+}
+
+// promotedType returns the type operands are converted to before they are
+// compared or combined. Numbers of different kinds, or dates and
+// timestamps, use their common type. Integers of any width are handled as
+// they are.
+func promotedType(lt, rt ColumnType) (ColumnType, bool) {
+	typ, ok := commonType(lt, rt)
+	return typ, ok && lt != rt && !(isIntegerType(lt) && isIntegerType(rt))
+}
+
+func promoteOperand(value MemoryCell, from, to ColumnType) (MemoryCell, error) {
+	if value.IsNull() {
+		return value, nil
 	}
 
+	return promoteCell(value, from, to)
+}
+
+// applyBinaryOperator applies the operator of bexp to operands that were
+// already typed and promoted
+func applyBinaryOperator(bexp binaryExpression, l, r MemoryCell, lt, rt ColumnType) (MemoryCell, ColumnType, error) {
 	switch bexp.op.kind {
 	case symbolKind:
 		switch symbol(bexp.op.value) {
 		case EqSymbol:
-			eq := l.equals(r)
-			if lt == TextType && rt == TextType && eq {
-				return trueMemoryCell, "?column?", BoolType, nil
+			if !comparableTypes(lt, rt) {
+				return MemoryCell{}, 0, ErrInvalidOperands
 			}
 
-			if lt == IntType && rt == IntType && eq {
-				return trueMemoryCell, "?column?", BoolType, nil
+			if l.IsNull() || r.IsNull() {
+				return MemoryCell{}, BoolType, nil
 			}
 
-			if lt == BoolType && rt == BoolType && eq {
-				return trueMemoryCell, "?column?", BoolType, nil
+			return boolToMemoryCell(cellsEqual(l, r, lt, rt)), BoolType, nil
+		case NeqSymbol:
+			if !comparableTypes(lt, rt) {
+				return MemoryCell{}, 0, ErrInvalidOperands
 			}
 
-			return falseMemoryCell, "?column?", BoolType, nil
-		case NeqSymbol:
-			if lt != rt || !l.equals(r) {
-				return trueMemoryCell, "?column?", BoolType, nil
+			if l.IsNull() || r.IsNull() {
+				return MemoryCell{}, BoolType, nil
 			}
 
-			return falseMemoryCell, "?column?", BoolType, nil
+			return boolToMemoryCell(!cellsEqual(l, r, lt, rt)), BoolType, nil
+		case LtSymbol, LteSymbol, GtSymbol, GteSymbol:
+			if !comparableTypes(lt, rt) {
+				return MemoryCell{}, 0, ErrInvalidOperands
+			}
+
+			if l.IsNull() || r.IsNull() {
+				return MemoryCell{}, BoolType, nil
+			}
+
+			c := compareCells(l, r, lt)
+			res := false
+			switch symbol(bexp.op.value) {
+			case LtSymbol:
+				res = c < 0
+			case LteSymbol:
+				res = c <= 0
+			case GtSymbol:
+				res = c > 0
+			case GteSymbol:
+				res = c >= 0
+			}
+
+			return boolToMemoryCell(res), BoolType, nil
 		case ConcatSymbol:
 			if lt != TextType || rt != TextType {
-				return nil, "", 0, ErrInvalidOperands
+				return MemoryCell{}, 0, ErrInvalidOperands
 			}
 
-			return literalToMemoryCell(&Token{kind: stringKind, value: l.AsText() + r.AsText()}), "?column?", TextType, nil
-		case PlusSymbol:
-			if lt != IntType || rt != IntType {
-				return nil, "", 0, ErrInvalidOperands
+			if l.IsNull() || r.IsNull() {
+				return MemoryCell{}, TextType, nil
 			}
 
-			iValue := int(l.AsInt() + r.AsInt())
-			return literalToMemoryCell(&Token{kind: numericKind, value: strconv.Itoa(iValue)}), "?column?", IntType, nil
+			return textCell(l.AsText() + r.AsText()), TextType, nil
+		case PlusSymbol, MinusSymbol, asteriskSymbol, SlashSymbol, PercentSymbol:
+			if isTemporalType(lt) || isTemporalType(rt) {
+				typ, ok := temporalResultType(symbol(bexp.op.value), lt, rt)
+				if !ok {
+					return MemoryCell{}, 0, ErrInvalidOperands
+				}
+
+				if l.IsNull() || r.IsNull() {
+					return MemoryCell{}, typ, nil
+				}
+
+				value, err := temporalArithmetic(symbol(bexp.op.value), l, r, lt, rt)
+				if err != nil {
+					return MemoryCell{}, 0, err
+				}
+
+				return value, typ, nil
+			}
+
+			if !isNumericType(lt) || !isNumericType(rt) {
+				return MemoryCell{}, 0, ErrInvalidOperands
+			}
+
+			// The result has the type of the wider operand
+			typ := commonNumericType(lt, rt)
+			if l.IsNull() || r.IsNull() {
+				return MemoryCell{}, typ, nil
+			}
+
+			switch {
+			case isIntegerType(typ):
+				iValue, err := integerArithmetic(symbol(bexp.op.value), l.AsInt64(), r.AsInt64())
+				if err != nil {
+					return MemoryCell{}, 0, err
+				}
+
+				value, _, _, err := intToMemoryCell(iValue, typ)
+				return value, typ, err
+			case typ == NumericType:
+				value, err := numericArithmetic(symbol(bexp.op.value), l, r)
+				if err != nil {
+					return MemoryCell{}, 0, err
+				}
+
+				return value, NumericType, nil
+			default:
+				fValue, err := floatArithmetic(symbol(bexp.op.value), l.AsFloat64(), r.AsFloat64())
+				if err != nil {
+					return MemoryCell{}, 0, err
+				}
+
+				value, _, _, err := floatToMemoryCell(fValue, typ)
+				return value, typ, err
+			}
 		default:
 			// TODO
 			break
@@ -151,42 +619,250 @@ func (t *table) evaluateBinaryCell(rowIndex uint, exp expression) (MemoryCell, s
 		switch keyword(bexp.op.value) {
 		case AndKeyword:
 			if lt != BoolType || rt != BoolType {
-				return nil, "", 0, ErrInvalidOperands
+				return MemoryCell{}, 0, ErrInvalidOperands
 			}
 
-			res := falseMemoryCell
-			if l.AsBool() && r.AsBool() {
-				res = trueMemoryCell
+			// false wins over NULL, NULL wins over true
+			if (!l.IsNull() && !l.AsBool()) || (!r.IsNull() && !r.AsBool()) {
+				return falseMemoryCell, BoolType, nil
 			}
 
-			return res, "?column?", BoolType, nil
+			if l.IsNull() || r.IsNull() {
+				return MemoryCell{}, BoolType, nil
+			}
+
+			return trueMemoryCell, BoolType, nil
 		case OrKeyword:
 			if lt != BoolType || rt != BoolType {
-				return nil, "", 0, ErrInvalidOperands
+				return MemoryCell{}, 0, ErrInvalidOperands
 			}
 
-			res := falseMemoryCell
+			// true wins over NULL, NULL wins over false
 			if l.AsBool() || r.AsBool() {
-				res = trueMemoryCell
+				return trueMemoryCell, BoolType, nil
+			}
+
+			if l.IsNull() || r.IsNull() {
+				return MemoryCell{}, BoolType, nil
+			}
+
+			return falseMemoryCell, BoolType, nil
+		case IsKeyword:
+			// The parser only allows NULL, TRUE or FALSE on the right
+			if isNullLiteral(bexp.b) {
+				return boolToMemoryCell(l.IsNull()), BoolType, nil
+			}
+
+			if lt != BoolType || rt != BoolType {
+				return MemoryCell{}, 0, ErrInvalidOperands
 			}
 
-			return res, "?column?", BoolType, nil
+			return boolToMemoryCell(!l.IsNull() && l.AsBool() == r.AsBool()), BoolType, nil
 		default:
 			// TODO
 			break
 		}
 	}
 
-	return nil, "", 0, ErrInvalidCell
+	return MemoryCell{}, 0, ErrInvalidCell
 }
 
-func (t *table) evaluateCell(rowIndex uint, exp expression) (MemoryCell, string, ColumnType, error) {
-	switch exp.kind {
-	case literalKind:
-		return t.evaluateLiteralCell(rowIndex, exp)
-	case binaryKind:
-		return t.evaluateBinaryCell(rowIndex, exp)
-	default:
-		return nil, "", 0, ErrInvalidCell
+func boolToMemoryCell(b bool) MemoryCell {
+	if b {
+		return trueMemoryCell
 	}
+
+	return falseMemoryCell
+}
+
+// cellsEqual compares two non-NULL cells, numbers are equal when their
+// values are whatever their width or scale
+func cellsEqual(l, r MemoryCell, lt, rt ColumnType) bool {
+	if isIntegerType(lt) && isIntegerType(rt) {
+		return l.AsInt64() == r.AsInt64()
+	}
+
+	if lt == rt && (isNumericType(lt) || isTemporalType(lt)) {
+		return compareCells(l, r, lt) == 0
+	}
+
+	return lt == rt && l.equals(r)
+}
+
+// comparableTypes reports whether values of lt and rt can be ordered
+// against each other
+func comparableTypes(lt, rt ColumnType) bool {
+	if isIntegerType(lt) && isIntegerType(rt) {
+		return true
+	}
+
+	return lt == rt && (lt == TextType || lt == BoolType || isNumericType(lt) || isTemporalType(lt))
+}
+
+// commonType returns the type values of types a and b are converted to
+// before they are compared or combined, if they can be
+func commonType(a, b ColumnType) (ColumnType, bool) {
+	if isNumericType(a) && isNumericType(b) {
+		return commonNumericType(a, b), true
+	}
+
+	if isDatetimeType(a) && isDatetimeType(b) {
+		return commonDatetimeType(a, b), true
+	}
+
+	return 0, false
+}
+
+// promoteCell converts a non-NULL value to the type commonType chose
+func promoteCell(value MemoryCell, from, to ColumnType) (MemoryCell, error) {
+	if isNumericType(to) {
+		return castNumber(value, from, to)
+	}
+
+	return castDatetime(value, from, to)
+}
+
+func isComparison(op symbol) bool {
+	switch op {
+	case EqSymbol, NeqSymbol, LtSymbol, LteSymbol, GtSymbol, GteSymbol:
+		return true
+	}
+
+	return false
+}
+
+// integerArithmetic applies an arithmetic operator to two integers,
+// failing instead of wrapping around when the result does not fit in 64
+// bits
+func integerArithmetic(op symbol, a, b int64) (int64, error) {
+	switch op {
+	case PlusSymbol:
+		if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+			return 0, ErrIntegerOutOfRange
+		}
+
+		return a + b, nil
+	case MinusSymbol:
+		if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+			return 0, ErrIntegerOutOfRange
+		}
+
+		return a - b, nil
+	case asteriskSymbol:
+		if a == 0 || b == 0 {
+			return 0, nil
+		}
+
+		res := a * b
+		if res/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+			return 0, ErrIntegerOutOfRange
+		}
+
+		return res, nil
+	case SlashSymbol:
+		if b == 0 {
+			return 0, ErrDivisionByZero
+		}
+
+		if a == math.MinInt64 && b == -1 {
+			return 0, ErrIntegerOutOfRange
+		}
+
+		return a / b, nil
+	case PercentSymbol:
+		if b == 0 {
+			return 0, ErrDivisionByZero
+		}
+
+		return a % b, nil
+	}
+
+	return 0, ErrInvalidOperands
+}
+
+// intToMemoryCell stores i as the integer type typ, failing if it is out
+// of range for typ
+func intToMemoryCell(i int64, typ ColumnType) (MemoryCell, string, ColumnType, error) {
+	min, max := intTypeRange(typ)
+	if i < min || i > max {
+		return MemoryCell{}, "", 0, ErrIntegerOutOfRange
+	}
+
+	return encodeInt(i, typ), "?column?", typ, nil
+}
+
+// applyUnaryOperator applies the operator of uexp to a value of type vt
+func applyUnaryOperator(uexp unaryExpression, v MemoryCell, vt ColumnType) (MemoryCell, ColumnType, error) {
+	switch uexp.op.kind {
+	case symbolKind:
+		switch symbol(uexp.op.value) {
+		case MinusSymbol:
+			if !isNumericType(vt) && vt != IntervalType {
+				return MemoryCell{}, 0, ErrInvalidOperands
+			}
+
+			if v.IsNull() {
+				return MemoryCell{}, vt, nil
+			}
+
+			switch {
+			case vt == IntervalType:
+				iv, err := addIntervals(Interval{}, v.AsInterval(), -1)
+				if err != nil {
+					return MemoryCell{}, 0, err
+				}
+
+				return encodeInterval(iv), IntervalType, nil
+			case isIntegerType(vt):
+				iValue, err := integerArithmetic(MinusSymbol, 0, v.AsInt64())
+				if err != nil {
+					return MemoryCell{}, 0, err
+				}
+
+				value, _, _, err := intToMemoryCell(iValue, vt)
+				return value, vt, err
+			case vt == NumericType:
+				neg := new(big.Rat).Neg(v.AsNumeric())
+				return numericCell(formatNumeric(neg, numericScale(v))), NumericType, nil
+			default:
+				value, _, _, err := floatToMemoryCell(-v.AsFloat64(), vt)
+				return value, vt, err
+			}
+		}
+	case keywordKind:
+		switch keyword(uexp.op.value) {
+		case NotKeyword:
+			if isNullLiteral(uexp.exp) {
+				vt = BoolType
+			}
+
+			if vt != BoolType {
+				return MemoryCell{}, 0, ErrInvalidOperands
+			}
+
+			if v.IsNull() {
+				return MemoryCell{}, BoolType, nil
+			}
+
+			return boolToMemoryCell(!v.AsBool()), BoolType, nil
+		}
+	}
+
+	return MemoryCell{}, 0, ErrInvalidCell
+}
+
+// evaluateCell evaluates exp for a single row. Statements evaluating an
+// expression over many rows compile it once instead.
+func (sc *scope) evaluateCell(rowIndex uint, exp expression) (MemoryCell, string, ColumnType, error) {
+	c, err := sc.compileExpression(exp)
+	if err != nil {
+		return MemoryCell{}, "", 0, err
+	}
+
+	value, err := c.eval(rowIndex)
+	if err != nil {
+		return MemoryCell{}, "", 0, err
+	}
+
+	return value, c.name, c.typ, nil
 }
@@ -0,0 +1,644 @@
+package pck
+
+import (
+	"sort"
+)
+
+// btreeDegree is the minimum degree of the index B-tree, every node but
+// the root holds between btreeDegree-1 and 2*btreeDegree-1 entries
+const btreeDegree = 32
+
+type indexEntry struct {
+	key      MemoryCell
+	rowIndex uint
+}
+
+type btreeNode struct {
+	items    []indexEntry
+	children []*btreeNode
+}
+
+type btree struct {
+	root *btreeNode
+	less func(a, b indexEntry) bool
+}
+
+func (bt *btree) insert(e indexEntry) {
+	if bt.root == nil {
+		bt.root = &btreeNode{items: []indexEntry{e}}
+		return
+	}
+
+	// Split a full root up front so insertion never has to walk back up
+	if len(bt.root.items) >= 2*btreeDegree-1 {
+		old := bt.root
+		bt.root = &btreeNode{children: []*btreeNode{old}}
+		bt.root.splitChild(0)
+	}
+
+	bt.root.insertNonFull(e, bt.less)
+}
+
+// ascend calls fn in order on every entry for which from returns true,
+// from must be monotonic over the ordering. Stops early if fn returns false.
+func (bt *btree) ascend(from func(indexEntry) bool, fn func(indexEntry) bool) {
+	if bt.root == nil {
+		return
+	}
+
+	bt.root.ascend(from, fn)
+}
+
+// delete removes e from the tree, reporting whether it was there
+func (bt *btree) delete(e indexEntry) bool {
+	if bt.root == nil {
+		return false
+	}
+
+	found := bt.root.delete(e, bt.less)
+
+	// A root left without entries hands over to its only child
+	if len(bt.root.items) == 0 {
+		if len(bt.root.children) > 0 {
+			bt.root = bt.root.children[0]
+		} else {
+			bt.root = nil
+		}
+	}
+
+	return found
+}
+
+// each calls fn on every entry, which may change the entry as long as
+// its order stays the same
+func (bt *btree) each(fn func(*indexEntry)) {
+	if bt.root != nil {
+		bt.root.each(fn)
+	}
+}
+
+func (n *btreeNode) each(fn func(*indexEntry)) {
+	for i := range n.items {
+		fn(&n.items[i])
+	}
+
+	for _, child := range n.children {
+		child.each(fn)
+	}
+}
+
+func (n *btreeNode) splitChild(i int) {
+	child := n.children[i]
+	mid := btreeDegree - 1
+	median := child.items[mid]
+
+	right := &btreeNode{items: append([]indexEntry{}, child.items[mid+1:]...)}
+	if len(child.children) > 0 {
+		right.children = append([]*btreeNode{}, child.children[mid+1:]...)
+		child.children = child.children[:mid+1]
+	}
+	child.items = child.items[:mid]
+
+	n.items = append(n.items, indexEntry{})
+	copy(n.items[i+1:], n.items[i:])
+	n.items[i] = median
+
+	n.children = append(n.children, nil)
+	copy(n.children[i+2:], n.children[i+1:])
+	n.children[i+1] = right
+}
+
+func (n *btreeNode) insertNonFull(e indexEntry, less func(a, b indexEntry) bool) {
+	i := sort.Search(len(n.items), func(j int) bool {
+		return less(e, n.items[j])
+	})
+
+	if len(n.children) == 0 {
+		n.items = append(n.items, indexEntry{})
+		copy(n.items[i+1:], n.items[i:])
+		n.items[i] = e
+		return
+	}
+
+	if len(n.children[i].items) >= 2*btreeDegree-1 {
+		n.splitChild(i)
+		if less(n.items[i], e) {
+			i++
+		}
+	}
+
+	n.children[i].insertNonFull(e, less)
+}
+
+// delete removes e from the subtree of n. It only descends into children
+// holding at least btreeDegree entries, so removing one never leaves a
+// node short and the tree never has to be fixed on the way back up.
+func (n *btreeNode) delete(e indexEntry, less func(a, b indexEntry) bool) bool {
+	i := sort.Search(len(n.items), func(j int) bool {
+		return !less(n.items[j], e)
+	})
+	found := i < len(n.items) && !less(e, n.items[i])
+
+	if len(n.children) == 0 {
+		if !found {
+			return false
+		}
+
+		n.items = append(n.items[:i], n.items[i+1:]...)
+		return true
+	}
+
+	if found {
+		// Replace e with its neighbour from a child that can spare one, or
+		// else merge both children around it
+		switch {
+		case len(n.children[i].items) >= btreeDegree:
+			n.items[i] = n.children[i].max()
+			return n.children[i].delete(n.items[i], less)
+		case len(n.children[i+1].items) >= btreeDegree:
+			n.items[i] = n.children[i+1].min()
+			return n.children[i+1].delete(n.items[i], less)
+		default:
+			n.merge(i)
+			return n.children[i].delete(e, less)
+		}
+	}
+
+	if len(n.children[i].items) < btreeDegree {
+		i = n.fill(i)
+	}
+
+	return n.children[i].delete(e, less)
+}
+
+func (n *btreeNode) min() indexEntry {
+	for len(n.children) > 0 {
+		n = n.children[0]
+	}
+
+	return n.items[0]
+}
+
+func (n *btreeNode) max() indexEntry {
+	for len(n.children) > 0 {
+		n = n.children[len(n.children)-1]
+	}
+
+	return n.items[len(n.items)-1]
+}
+
+// merge joins child i, the entry between them and child i+1 into child i
+func (n *btreeNode) merge(i int) {
+	child, right := n.children[i], n.children[i+1]
+	child.items = append(append(child.items, n.items[i]), right.items...)
+	child.children = append(child.children, right.children...)
+
+	n.items = append(n.items[:i], n.items[i+1:]...)
+	n.children = append(n.children[:i+1], n.children[i+2:]...)
+}
+
+// fill gives child i at least btreeDegree entries, borrowing one from a
+// sibling through n or merging it with a sibling. It returns the index
+// the child ends up at.
+func (n *btreeNode) fill(i int) int {
+	child := n.children[i]
+
+	if i > 0 && len(n.children[i-1].items) >= btreeDegree {
+		left := n.children[i-1]
+		child.items = append([]indexEntry{n.items[i-1]}, child.items...)
+		n.items[i-1] = left.items[len(left.items)-1]
+		left.items = left.items[:len(left.items)-1]
+
+		if len(left.children) > 0 {
+			child.children = append([]*btreeNode{left.children[len(left.children)-1]}, child.children...)
+			left.children = left.children[:len(left.children)-1]
+		}
+
+		return i
+	}
+
+	if i < len(n.children)-1 && len(n.children[i+1].items) >= btreeDegree {
+		right := n.children[i+1]
+		child.items = append(child.items, n.items[i])
+		n.items[i] = right.items[0]
+		right.items = append([]indexEntry{}, right.items[1:]...)
+
+		if len(right.children) > 0 {
+			child.children = append(child.children, right.children[0])
+			right.children = append([]*btreeNode{}, right.children[1:]...)
+		}
+
+		return i
+	}
+
+	if i < len(n.children)-1 {
+		n.merge(i)
+		return i
+	}
+
+	n.merge(i - 1)
+	return i - 1
+}
+
+func (n *btreeNode) ascend(from func(indexEntry) bool, fn func(indexEntry) bool) bool {
+	i := sort.Search(len(n.items), func(j int) bool {
+		return from(n.items[j])
+	})
+
+	for ; i <= len(n.items); i++ {
+		if len(n.children) > 0 {
+			if !n.children[i].ascend(from, fn) {
+				return false
+			}
+		}
+
+		if i < len(n.items) && !fn(n.items[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// index is an ordered index over a single column of a table. Entries
+// are ordered by key and then by row so duplicate keys stay distinct.
+type index struct {
+	name   string
+	column uint
+	typ    ColumnType
+	unique bool
+	tree   *btree
+
+	// constraint is set on the indexes enforcing a PRIMARY KEY or UNIQUE
+	// column, which can't be dropped on their own
+	constraint bool
+}
+
+func newIndex(name string, column uint, typ ColumnType, unique bool) *index {
+	idx := &index{
+		name:   name,
+		column: column,
+		typ:    typ,
+		unique: unique,
+	}
+
+	idx.tree = &btree{
+		less: func(a, b indexEntry) bool {
+			c := compareCells(a.key, b.key, idx.typ)
+			if c != 0 {
+				return c < 0
+			}
+
+			return a.rowIndex < b.rowIndex
+		},
+	}
+
+	return idx
+}
+
+// validate checks that adding a row with key to the index would not
+// violate its constraints
+func (idx *index) validate(key MemoryCell) error {
+	if !idx.unique || key.IsNull() {
+		return nil
+	}
+
+	exists := false
+	idx.scan(indexRange{
+		lower:          key,
+		hasLower:       true,
+		lowerInclusive: true,
+		upper:          key,
+		hasUpper:       true,
+		upperInclusive: true,
+	}, func(uint) bool {
+		exists = true
+		return false
+	})
+
+	if exists {
+		return ErrViolatesUniqueConstraint
+	}
+
+	return nil
+}
+
+func (idx *index) addRow(t *table, rowIndex uint) error {
+	return idx.add(t.cell(rowIndex, int(idx.column)), rowIndex)
+}
+
+func (idx *index) add(key MemoryCell, rowIndex uint) error {
+	if err := idx.validate(key); err != nil {
+		return err
+	}
+
+	// NULLs never equal each other so they are left out of the index
+	if key.IsNull() {
+		return nil
+	}
+
+	idx.tree.insert(indexEntry{
+		key:      key,
+		rowIndex: rowIndex,
+	})
+	return nil
+}
+
+func (idx *index) remove(key MemoryCell, rowIndex uint) {
+	if key.IsNull() {
+		return
+	}
+
+	idx.tree.delete(indexEntry{
+		key:      key,
+		rowIndex: rowIndex,
+	})
+}
+
+// deleteRows takes the entries of the deleted rows out of the index and
+// renumbers the others to the positions their rows move to once the
+// deleted ones are gone. deleted must be in order.
+func (idx *index) deleteRows(t *table, deleted []uint) {
+	if len(deleted) == 0 {
+		return
+	}
+
+	if uint(len(deleted)) == t.rowCount() {
+		idx.tree.root = nil
+		return
+	}
+
+	for _, rowIndex := range deleted {
+		idx.remove(t.cell(rowIndex, int(idx.column)), rowIndex)
+	}
+
+	// Removing rows keeps the others in order, so the entries stay sorted
+	idx.tree.each(func(e *indexEntry) {
+		e.rowIndex -= uint(sort.Search(len(deleted), func(i int) bool {
+			return deleted[i] > e.rowIndex
+		}))
+	})
+}
+
+// rowUpdate is a row of a table before and after an UPDATE
+type rowUpdate struct {
+	rowIndex uint
+	before   []MemoryCell
+	after    []MemoryCell
+}
+
+// updateRows moves the entries of the rows whose key changed to their
+// new key. Every old key is taken out before the new ones are checked,
+// so rows may swap keys. On failure the index is left as it was.
+func (idx *index) updateRows(updates []rowUpdate) error {
+	moved := []rowUpdate{}
+	for _, u := range updates {
+		before, after := u.before[idx.column], u.after[idx.column]
+		if before.IsNull() && after.IsNull() {
+			continue
+		}
+
+		if !before.IsNull() && !after.IsNull() && compareCells(before, after, idx.typ) == 0 {
+			continue
+		}
+
+		moved = append(moved, u)
+	}
+
+	for _, u := range moved {
+		idx.remove(u.before[idx.column], u.rowIndex)
+	}
+
+	for i, u := range moved {
+		if err := idx.add(u.after[idx.column], u.rowIndex); err != nil {
+			for _, added := range moved[:i] {
+				idx.remove(added.after[idx.column], added.rowIndex)
+			}
+
+			// The old keys were unique before, so they still are
+			for _, u := range moved {
+				idx.add(u.before[idx.column], u.rowIndex)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateIndexes moves the index entries of the updated rows. If a new key
+// violates a unique constraint every index is left as it was.
+func (t *table) updateIndexes(updates []rowUpdate) error {
+	for i, idx := range t.indexes {
+		if err := idx.updateRows(updates); err != nil {
+			undo := make([]rowUpdate, len(updates))
+			for j, u := range updates {
+				undo[j] = rowUpdate{u.rowIndex, u.after, u.before}
+			}
+
+			for _, updated := range t.indexes[:i] {
+				updated.updateRows(undo)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+type indexRange struct {
+	lower          MemoryCell
+	hasLower       bool
+	lowerInclusive bool
+	upper          MemoryCell
+	hasUpper       bool
+	upperInclusive bool
+}
+
+// intersect narrows r to the rows also matched by o
+func (idx *index) intersect(r, o indexRange) indexRange {
+	if o.hasLower {
+		tighter := !r.hasLower
+		if !tighter {
+			c := compareCells(o.lower, r.lower, idx.typ)
+			tighter = c > 0 || (c == 0 && !o.lowerInclusive)
+		}
+
+		if tighter {
+			r.lower, r.hasLower, r.lowerInclusive = o.lower, true, o.lowerInclusive
+		}
+	}
+
+	if o.hasUpper {
+		tighter := !r.hasUpper
+		if !tighter {
+			c := compareCells(o.upper, r.upper, idx.typ)
+			tighter = c < 0 || (c == 0 && !o.upperInclusive)
+		}
+
+		if tighter {
+			r.upper, r.hasUpper, r.upperInclusive = o.upper, true, o.upperInclusive
+		}
+	}
+
+	return r
+}
+
+func (idx *index) scan(r indexRange, fn func(rowIndex uint) bool) {
+	idx.tree.ascend(func(e indexEntry) bool {
+		if !r.hasLower {
+			return true
+		}
+
+		c := compareCells(e.key, r.lower, idx.typ)
+		return c > 0 || (c == 0 && r.lowerInclusive)
+	}, func(e indexEntry) bool {
+		if r.hasUpper {
+			c := compareCells(e.key, r.upper, idx.typ)
+			if c > 0 || (c == 0 && !r.upperInclusive) {
+				return false
+			}
+		}
+
+		return fn(e.rowIndex)
+	})
+}
+
+func isColumnReference(t *table, exp expression, column uint) bool {
+	if exp.kind != literalKind || exp.literal.kind != identifierKind {
+		return false
+	}
+
+	i, err := t.columnIndex(exp.literal.value)
+	return err == nil && uint(i) == column
+}
+
+// rangeFor finds the range of keys matched by an equality or range
+// predicate on the indexed column. Conjunctions narrow the range, any
+// other expression means the index is not applicable.
+func (idx *index) rangeFor(t *table, exp expression) (indexRange, bool) {
+	if exp.kind != binaryKind {
+		return indexRange{}, false
+	}
+
+	bexp := exp.binary
+	if bexp.op.kind == keywordKind && keyword(bexp.op.value) == AndKeyword {
+		l, lok := idx.rangeFor(t, bexp.a)
+		r, rok := idx.rangeFor(t, bexp.b)
+		if lok && rok {
+			return idx.intersect(l, r), true
+		} else if lok {
+			return l, true
+		}
+
+		return r, rok
+	}
+
+	if bexp.op.kind != symbolKind {
+		return indexRange{}, false
+	}
+
+	op := symbol(bexp.op.value)
+	value := bexp.b
+	if !isColumnReference(t, bexp.a, idx.column) {
+		if !isColumnReference(t, bexp.b, idx.column) {
+			return indexRange{}, false
+		}
+
+		// Normalize `5 < col` into `col > 5`
+		value = bexp.a
+		switch op {
+		case LtSymbol:
+			op = GtSymbol
+		case LteSymbol:
+			op = GteSymbol
+		case GtSymbol:
+			op = LtSymbol
+		case GteSymbol:
+			op = LteSymbol
+		}
+	}
+
+	// Typed literals are casts of a string so they are constant too
+	constant := value.kind == castKind ||
+		(value.kind == literalKind && value.literal.kind != identifierKind && value.literal.kind != nullKind)
+	if !constant {
+		return indexRange{}, false
+	}
+
+	empty := &scope{t: &table{}}
+	key, _, typ, err := empty.evaluateCell(0, value)
+	if err != nil {
+		return indexRange{}, false
+	}
+
+	// An untyped string is read as the indexed type like the evaluator does
+	if isStringLiteral(value) && idx.typ != TextType {
+		if key, err = coerceCell(key, typ, idx.typ, true); err != nil {
+			return indexRange{}, false
+		}
+		typ = idx.typ
+	}
+
+	if typ != idx.typ && !(isIntegerType(typ) && isIntegerType(idx.typ)) {
+		// Only widen the key to the indexed type, narrowing it would change
+		// which rows match
+		if common, ok := commonType(typ, idx.typ); !ok || common != idx.typ {
+			return indexRange{}, false
+		}
+
+		if key, err = promoteCell(key, typ, idx.typ); err != nil {
+			return indexRange{}, false
+		}
+	}
+
+	switch op {
+	case EqSymbol:
+		return indexRange{
+			lower:          key,
+			hasLower:       true,
+			lowerInclusive: true,
+			upper:          key,
+			hasUpper:       true,
+			upperInclusive: true,
+		}, true
+	case LtSymbol, LteSymbol:
+		return indexRange{
+			upper:          key,
+			hasUpper:       true,
+			upperInclusive: op == LteSymbol,
+		}, true
+	case GtSymbol, GteSymbol:
+		return indexRange{
+			lower:          key,
+			hasLower:       true,
+			lowerInclusive: op == GteSymbol,
+		}, true
+	}
+
+	return indexRange{}, false
+}
+
+// newTableFromSubset returns a copy of t holding only the rows the index
+// matches for where, in their original order. The where expression must
+// still be applied to the result.
+func (idx *index) newTableFromSubset(t *table, where expression) (*table, bool) {
+	r, ok := idx.rangeFor(t, where)
+	if !ok {
+		return nil, false
+	}
+
+	rowIndexes := []uint{}
+	idx.scan(r, func(rowIndex uint) bool {
+		rowIndexes = append(rowIndexes, rowIndex)
+		return true
+	})
+
+	sort.Slice(rowIndexes, func(i, j int) bool {
+		return rowIndexes[i] < rowIndexes[j]
+	})
+
+	return t.subset(rowIndexes), true
+}
@@ -0,0 +1,375 @@
+package pck
+
+// fromTable returns the table a FROM item reads from, with its columns
+// qualified by the name of the item
+func (mb *MemoryBackend) fromTable(item fromItem, outer *outerRow) (*table, error) {
+	switch item.kind {
+	case joinFromKind:
+		return mb.join(*item.join, outer)
+	case subqueryFromKind:
+		return mb.derivedTable(item, outer)
+	}
+
+	t, ok := mb.tables[item.table.value]
+	if !ok {
+		return nil, ErrTableDoesNotExist
+	}
+
+	name := item.table.value
+	if item.alias != nil {
+		name = item.alias.value
+	}
+
+	if outer.describing() {
+		return t.qualified(name).emptyCopy(), nil
+	}
+
+	return t.qualified(name), nil
+}
+
+// qualified returns a table sharing the rows and indexes of t, with its
+// columns qualified by name
+func (t *table) qualified(name string) *table {
+	c := *t
+	c.qualifiers = make([]string, len(t.columns))
+	for i := range c.qualifiers {
+		c.qualifiers[i] = name
+	}
+	c.qualifiedOnly = nil
+
+	return &c
+}
+
+func (t *table) isQualifiedOnly(column int) bool {
+	return t.qualifiedOnly != nil && t.qualifiedOnly[column]
+}
+
+// addColumn appends a column to a table built while running a query
+func (t *table) addColumn(name string, typ ColumnType, qualifier string, qualifiedOnly bool) {
+	t.columns = append(t.columns, name)
+	t.columnTypes = append(t.columnTypes, typ)
+	t.typmods = append(t.typmods, numericTypmod{})
+	t.constraints = append(t.constraints, columnConstraints{})
+	t.qualifiers = append(t.qualifiers, qualifier)
+	t.qualifiedOnly = append(t.qualifiedOnly, qualifiedOnly)
+}
+
+// joinKey is a column of each side of a join that matching rows have
+// equal values in. Both are converted to typ so equal values hash the
+// same.
+type joinKey struct {
+	left  int
+	right int
+	typ   ColumnType
+}
+
+// joinKeyType returns the type the values of two join key columns are
+// hashed as. Intervals can be equal without being written the same, so
+// they are never hashed.
+func joinKeyType(lt, rt ColumnType) (ColumnType, bool) {
+	if lt == IntervalType || rt == IntervalType {
+		return 0, false
+	}
+
+	if lt == rt {
+		return lt, true
+	}
+
+	return commonType(lt, rt)
+}
+
+// hashKey encodes the key columns of a row, it fails for rows with a NULL
+// key which never match
+func hashKey(t *table, rowIndex uint, columns []int, keys []joinKey) (string, bool, error) {
+	cells := make([]MemoryCell, len(keys))
+	for i, key := range keys {
+		cell := t.cell(rowIndex, columns[i])
+		if cell.IsNull() {
+			return "", false, nil
+		}
+
+		from := t.columnTypes[columns[i]]
+		if from != key.typ && !(isIntegerType(from) && isIntegerType(key.typ)) {
+			var err error
+			if cell, err = promoteCell(cell, from, key.typ); err != nil {
+				return "", false, err
+			}
+		}
+
+		cells[i] = cell
+	}
+
+	return groupKey(cells), true, nil
+}
+
+// joinColumn resolves exp to a column of t which isn't also a column of
+// other
+func joinColumn(t, other *table, exp expression) (int, bool) {
+	if exp.kind != literalKind || exp.literal.kind != identifierKind {
+		return 0, false
+	}
+
+	column, err := t.columnIndex(exp.literal.value)
+	if err != nil {
+		return 0, false
+	}
+
+	if _, err := other.columnIndex(exp.literal.value); err != ErrColumnDoesNotExist {
+		return 0, false
+	}
+
+	return column, true
+}
+
+// equiJoinKeys finds the equalities between a column of l and a column of
+// r among the conditions on requires, which rows can be hashed on
+func equiJoinKeys(l, r *table, on expression) []joinKey {
+	if on.kind != binaryKind {
+		return nil
+	}
+
+	bexp := on.binary
+	if bexp.op.kind == keywordKind && keyword(bexp.op.value) == AndKeyword {
+		return append(equiJoinKeys(l, r, bexp.a), equiJoinKeys(l, r, bexp.b)...)
+	}
+
+	if bexp.op.kind != symbolKind || symbol(bexp.op.value) != EqSymbol {
+		return nil
+	}
+
+	a, b := bexp.a, bexp.b
+	left, ok := joinColumn(l, r, a)
+	if !ok {
+		a, b = b, a
+		if left, ok = joinColumn(l, r, a); !ok {
+			return nil
+		}
+	}
+
+	right, ok := joinColumn(r, l, b)
+	if !ok {
+		return nil
+	}
+
+	typ, ok := joinKeyType(l.columnTypes[left], r.columnTypes[right])
+	if !ok {
+		return nil
+	}
+
+	return []joinKey{{left, right, typ}}
+}
+
+// join builds the table of the rows of a join. Columns merged by USING
+// come first, then the columns of the left and of the right side. Rows
+// are matched with a hash table when they must have equal values in some
+// columns, or else by trying every pair.
+func (mb *MemoryBackend) join(j joinExpression, outer *outerRow) (*table, error) {
+	l, err := mb.fromTable(j.a, outer)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := mb.fromTable(j.b, outer)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, qualifier := range l.qualifiers {
+		names[qualifier] = qualifier != ""
+	}
+
+	for _, qualifier := range r.qualifiers {
+		if names[qualifier] {
+			return nil, ErrDuplicateTableName
+		}
+	}
+
+	result := &table{}
+	using := []joinKey{}
+	merged := map[int]bool{}
+	if j.using != nil {
+		mergedRight := map[int]bool{}
+		for _, column := range *j.using {
+			left, err := l.columnIndex(column.value)
+			if err != nil {
+				return nil, err
+			}
+
+			right, err := r.columnIndex(column.value)
+			if err != nil {
+				return nil, err
+			}
+
+			if merged[left] || mergedRight[right] {
+				return nil, ErrColumnAlreadyExists
+			}
+
+			typ, ok := joinKeyType(l.columnTypes[left], r.columnTypes[right])
+			if !ok {
+				return nil, ErrInvalidOperands
+			}
+
+			using = append(using, joinKey{left, right, typ})
+			merged[left], mergedRight[right] = true, true
+			result.addColumn(column.value, typ, "", false)
+		}
+
+		// The merged columns are left out of the columns of their side
+		for column := range mergedRight {
+			merged[len(l.columns)+column] = true
+		}
+	}
+
+	// The ON condition is evaluated over the pairs of rows it may match,
+	// a table holding the columns of both sides
+	pairs := &table{}
+	for _, side := range []*table{l, r} {
+		for i, column := range side.columns {
+			pairs.addColumn(column, side.columnTypes[i], side.qualifiers[i], side.isQualifiedOnly(i))
+		}
+	}
+
+	for i, column := range pairs.columns {
+		result.addColumn(column, pairs.columnTypes[i], pairs.qualifiers[i], pairs.qualifiedOnly[i] || merged[i])
+	}
+
+	keys := using
+	var on *compiledExpression
+	if j.on != nil {
+		c, err := mb.scope(pairs, outer).compileCondition(*j.on)
+		if err != nil {
+			return nil, err
+		}
+
+		on = &c
+		keys = equiJoinKeys(l, r, *j.on)
+	}
+
+	leftColumns, rightColumns := []int{}, []int{}
+	for _, key := range keys {
+		leftColumns = append(leftColumns, key.left)
+		rightColumns = append(rightColumns, key.right)
+	}
+
+	var buckets map[string][]uint
+	if len(keys) > 0 {
+		buckets = map[string][]uint{}
+		for i := uint(0); i < r.rowCount(); i++ {
+			key, ok, err := hashKey(r, i, rightColumns, keys)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				buckets[key] = append(buckets[key], i)
+			}
+		}
+	}
+
+	emit := func(left, right []MemoryCell) error {
+		row := []MemoryCell{}
+		for _, key := range using {
+			// Take the value from the side that has a row, converted to
+			// the type of the merged column
+			value, typ := MemoryCell{}, key.typ
+			if left != nil {
+				value, typ = left[key.left], l.columnTypes[key.left]
+			}
+
+			if value.IsNull() && right != nil {
+				value, typ = right[key.right], r.columnTypes[key.right]
+			}
+
+			if !value.IsNull() && typ != key.typ {
+				var err error
+				if value, err = promoteCell(value, typ, key.typ); err != nil {
+					return err
+				}
+			}
+
+			row = append(row, value)
+		}
+
+		if left == nil {
+			left = make([]MemoryCell, len(l.columns))
+		}
+
+		if right == nil {
+			right = make([]MemoryCell, len(r.columns))
+		}
+
+		row = append(row, left...)
+		row = append(row, right...)
+		result.appendRow(row)
+		return nil
+	}
+
+	matchedRight := make([]bool, r.rowCount())
+	for i := uint(0); i < l.rowCount(); i++ {
+		var candidates []uint
+		if buckets != nil {
+			key, ok, err := hashKey(l, i, leftColumns, keys)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				candidates = buckets[key]
+			}
+		} else {
+			candidates = make([]uint, r.rowCount())
+			for k := range candidates {
+				candidates[k] = uint(k)
+			}
+		}
+
+		left := l.row(i)
+		if on != nil {
+			pairs.rows = pairs.rows[:0]
+			for _, candidate := range candidates {
+				pairs.rows = append(pairs.rows, append(append([]MemoryCell{}, left...), r.row(candidate)...))
+			}
+		}
+
+		matched := false
+		for k, candidate := range candidates {
+			if on != nil {
+				value, err := on.eval(uint(k))
+				if err != nil {
+					return nil, err
+				}
+
+				if !value.AsBool() {
+					continue
+				}
+			}
+
+			matched = true
+			matchedRight[candidate] = true
+			if err := emit(left, r.row(candidate)); err != nil {
+				return nil, err
+			}
+		}
+
+		if !matched && (j.kind == leftJoin || j.kind == fullJoin) {
+			if err := emit(left, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if j.kind == rightJoin || j.kind == fullJoin {
+		for i, matched := range matchedRight {
+			if matched {
+				continue
+			}
+
+			if err := emit(nil, r.row(uint(i))); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,341 @@
+package pck
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// numericDivScale is the least number of fractional digits kept when
+	// dividing numerics, so 1 / 3.0 doesn't collapse to 0
+	numericDivScale = 20
+
+	// numericMaxPrecision bounds NUMERIC(p,s) and the digits a numeric
+	// literal may have on either side of the decimal point
+	numericMaxPrecision = 1000
+)
+
+func isFloatType(c ColumnType) bool {
+	return c == RealType || c == DoubleType
+}
+
+// isNumericType reports whether c holds numbers of any kind
+func isNumericType(c ColumnType) bool {
+	return isIntegerType(c) || isFloatType(c) || c == NumericType
+}
+
+// commonNumericType returns the type two numbers are converted to before
+// they are compared or combined. Integers widen to numeric, which widens
+// to real and then to double precision.
+func commonNumericType(a, b ColumnType) ColumnType {
+	if isIntegerType(a) && isIntegerType(b) {
+		return widerIntType(a, b)
+	}
+
+	rank := func(c ColumnType) int {
+		switch c {
+		case NumericType:
+			return 1
+		case RealType:
+			return 2
+		case DoubleType:
+			return 3
+		default:
+			return 0
+		}
+	}
+
+	if rank(a) > rank(b) {
+		return a
+	}
+
+	return b
+}
+
+func encodeFloat(f float64, typ ColumnType) MemoryCell {
+	if typ == RealType {
+		f = float64(float32(f))
+	}
+
+	return MemoryCell{typ: typ, valid: true, i: int64(math.Float64bits(f))}
+}
+
+// floatToMemoryCell stores f as the float type typ, failing if a finite
+// value is too large for it
+func floatToMemoryCell(f float64, typ ColumnType) (MemoryCell, string, ColumnType, error) {
+	if typ == RealType && !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+		return MemoryCell{}, "", 0, ErrFloatOutOfRange
+	}
+
+	return encodeFloat(f, typ), "?column?", typ, nil
+}
+
+// formatFloat renders a float the way PostgreSQL prints it
+func formatFloat(f float64, typ ColumnType) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+
+	bits := 64
+	if typ == RealType {
+		bits = 32
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, bits)
+}
+
+// numericScale returns the number of fractional digits of a numeric cell
+func numericScale(mc MemoryCell) int {
+	i := strings.IndexByte(mc.s, '.')
+	if i < 0 {
+		return 0
+	}
+
+	return len(mc.s) - i - 1
+}
+
+// formatNumeric renders r rounded to scale fractional digits, halves
+// round away from zero
+func formatNumeric(r *big.Rat, scale int) string {
+	s := r.FloatString(scale)
+	if strings.HasPrefix(s, "-") && strings.Trim(s, "-0.") == "" {
+		// Don't keep the sign of a value that rounded to zero
+		return s[1:]
+	}
+
+	return s
+}
+
+// parseNumericLiteral reads a numeric literal. Integers are typed as
+// integer when they fit in 32 bits and as bigint when they fit in 64,
+// anything else, including decimals and exponents, is a numeric.
+func parseNumericLiteral(value string) (MemoryCell, ColumnType, error) {
+	if !strings.ContainsAny(value, ".eE") {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			if i < math.MinInt32 || i > math.MaxInt32 {
+				return encodeInt(i, BigIntType), BigIntType, nil
+			}
+
+			return encodeInt(i, IntType), IntType, nil
+		}
+
+		if !errors.Is(err, strconv.ErrRange) {
+			return MemoryCell{}, 0, ErrInvalidCell
+		}
+	}
+
+	mantissa, exponent := value, 0
+	if i := strings.IndexAny(value, "eE"); i >= 0 {
+		var err error
+		mantissa = value[:i]
+		exponent, err = strconv.Atoi(value[i+1:])
+		if err != nil {
+			return MemoryCell{}, 0, ErrInvalidCell
+		}
+	}
+
+	// Check the exponent before expanding it, 1e999999999 would
+	// otherwise take forever
+	if exponent > numericMaxPrecision || exponent < -numericMaxPrecision {
+		return MemoryCell{}, 0, ErrNumericFieldOverflow
+	}
+
+	scale := 0
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		scale = len(mantissa) - i - 1
+	}
+
+	scale -= exponent
+	if scale < 0 {
+		scale = 0
+	}
+
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return MemoryCell{}, 0, ErrInvalidCell
+	}
+
+	return numericCell(formatNumeric(r, scale)), NumericType, nil
+}
+
+// castNumber converts a non-NULL number between numeric types. Integer
+// targets round and check their range, numerics keep the digits of the
+// value they were made from.
+func castNumber(value MemoryCell, from, to ColumnType) (MemoryCell, error) {
+	if from == to {
+		return value, nil
+	}
+
+	switch {
+	case isIntegerType(to):
+		var i int64
+		switch {
+		case isIntegerType(from):
+			i = value.AsInt64()
+		case from == NumericType:
+			var err error
+			i, err = strconv.ParseInt(formatNumeric(value.AsNumeric(), 0), 10, 64)
+			if err != nil {
+				return MemoryCell{}, ErrIntegerOutOfRange
+			}
+		default:
+			// Floats round half to even like PostgreSQL's rint
+			f := math.RoundToEven(value.AsFloat64())
+			if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+				return MemoryCell{}, ErrIntegerOutOfRange
+			}
+
+			i = int64(f)
+		}
+
+		cell, _, _, err := intToMemoryCell(i, to)
+		return cell, err
+	case to == NumericType:
+		if isIntegerType(from) {
+			return numericCell(strconv.FormatInt(value.AsInt64(), 10)), nil
+		}
+
+		f := value.AsFloat64()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return MemoryCell{}, ErrNumericFieldOverflow
+		}
+
+		bits := 64
+		if from == RealType {
+			bits = 32
+		}
+
+		return numericCell(strconv.FormatFloat(f, 'f', -1, bits)), nil
+	default:
+		var f float64
+		switch {
+		case isIntegerType(from):
+			f = float64(value.AsInt64())
+		case from == NumericType:
+			f, _ = value.AsNumeric().Float64()
+		default:
+			f = value.AsFloat64()
+		}
+
+		cell, _, _, err := floatToMemoryCell(f, to)
+		return cell, err
+	}
+}
+
+// numericArithmetic applies an arithmetic operator to two numerics. The
+// result keeps the larger scale of the operands, products add them up
+// and quotients keep at least numericDivScale digits.
+func numericArithmetic(op symbol, l, r MemoryCell) (MemoryCell, error) {
+	a, b := l.AsNumeric(), r.AsNumeric()
+	scale := numericScale(l)
+	if s := numericScale(r); s > scale {
+		scale = s
+	}
+
+	res := new(big.Rat)
+	switch op {
+	case PlusSymbol:
+		res.Add(a, b)
+	case MinusSymbol:
+		res.Sub(a, b)
+	case asteriskSymbol:
+		res.Mul(a, b)
+		scale = numericScale(l) + numericScale(r)
+	case SlashSymbol:
+		if b.Sign() == 0 {
+			return MemoryCell{}, ErrDivisionByZero
+		}
+
+		res.Quo(a, b)
+		if scale < numericDivScale {
+			scale = numericDivScale
+		}
+	case PercentSymbol:
+		if b.Sign() == 0 {
+			return MemoryCell{}, ErrDivisionByZero
+		}
+
+		// The remainder takes the sign of the dividend
+		q := new(big.Rat).Quo(a, b)
+		trunc := new(big.Int).Quo(q.Num(), q.Denom())
+		res.Sub(a, new(big.Rat).Mul(b, new(big.Rat).SetInt(trunc)))
+	default:
+		return MemoryCell{}, ErrInvalidOperands
+	}
+
+	return numericCell(formatNumeric(res, scale)), nil
+}
+
+// floatArithmetic applies an arithmetic operator to two floats. Like
+// PostgreSQL it fails when finite operands overflow to infinity.
+func floatArithmetic(op symbol, a, b float64) (float64, error) {
+	var res float64
+	switch op {
+	case PlusSymbol:
+		res = a + b
+	case MinusSymbol:
+		res = a - b
+	case asteriskSymbol:
+		res = a * b
+	case SlashSymbol:
+		if b == 0 {
+			return 0, ErrDivisionByZero
+		}
+
+		res = a / b
+	default:
+		return 0, ErrInvalidOperands
+	}
+
+	if math.IsInf(res, 0) && !math.IsInf(a, 0) && !math.IsInf(b, 0) {
+		return 0, ErrFloatOutOfRange
+	}
+
+	return res, nil
+}
+
+// newNumericTypmod reads the modifiers of NUMERIC(p,s), the scale
+// defaults to 0
+func newNumericTypmod(precision, scale *Token) (numericTypmod, error) {
+	tm := numericTypmod{}
+
+	var err error
+	tm.precision, err = strconv.Atoi(precision.value)
+	if err != nil || tm.precision < 1 || tm.precision > numericMaxPrecision {
+		return tm, ErrInvalidTypeModifier
+	}
+
+	if scale != nil {
+		tm.scale, err = strconv.Atoi(scale.value)
+		if err != nil || tm.scale < 0 || tm.scale > tm.precision {
+			return tm, ErrInvalidTypeModifier
+		}
+	}
+
+	return tm, nil
+}
+
+// apply rounds a numeric to the scale of the column and checks it has no
+// more digits than the precision allows
+func (tm numericTypmod) apply(value MemoryCell) (MemoryCell, error) {
+	if tm.precision == 0 || value.IsNull() {
+		return value, nil
+	}
+
+	s := formatNumeric(value.AsNumeric(), tm.scale)
+	digits := strings.TrimLeft(strings.SplitN(strings.TrimPrefix(s, "-"), ".", 2)[0], "0")
+	if len(digits) > tm.precision-tm.scale {
+		return MemoryCell{}, ErrNumericFieldOverflow
+	}
+
+	return numericCell(s), nil
+}
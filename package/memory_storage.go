@@ -0,0 +1,132 @@
+package pck
+
+// A table keeps its cells either row by row, which suits inserting and
+// updating single rows, or column by column, which keeps the cells a
+// filter reads next to each other and makes scanning a column cheap.
+// Everything outside this file goes through the accessors below and
+// works with both layouts.
+
+const (
+	// heapAccessMethod stores tables row by row, which is the default
+	heapAccessMethod = "heap"
+
+	// columnarAccessMethod stores tables column by column
+	columnarAccessMethod = "columnar"
+)
+
+func (t *table) rowCount() uint {
+	if !t.columnar {
+		return uint(len(t.rows))
+	}
+
+	if len(t.columnData) == 0 {
+		return 0
+	}
+
+	return uint(len(t.columnData[0]))
+}
+
+func (t *table) cell(rowIndex uint, column int) MemoryCell {
+	if t.columnar {
+		return t.columnData[column][rowIndex]
+	}
+
+	return t.rows[rowIndex][column]
+}
+
+// row returns the cells of a row, which must not be modified
+func (t *table) row(rowIndex uint) []MemoryCell {
+	if !t.columnar {
+		return t.rows[rowIndex]
+	}
+
+	row := make([]MemoryCell, len(t.columnData))
+	for i, column := range t.columnData {
+		row[i] = column[rowIndex]
+	}
+
+	return row
+}
+
+func (t *table) appendRow(row []MemoryCell) {
+	if !t.columnar {
+		t.rows = append(t.rows, row)
+		return
+	}
+
+	for i, cell := range row {
+		t.columnData[i] = append(t.columnData[i], cell)
+	}
+}
+
+// replaceRow overwrites the cells of a row
+func (t *table) replaceRow(rowIndex uint, row []MemoryCell) {
+	if !t.columnar {
+		t.rows[rowIndex] = row
+		return
+	}
+
+	for i, cell := range row {
+		t.columnData[i][rowIndex] = cell
+	}
+}
+
+// appendRowFrom appends a row of another table with the same columns
+func (t *table) appendRowFrom(o *table, rowIndex uint) {
+	if !t.columnar || !o.columnar {
+		t.appendRow(o.row(rowIndex))
+		return
+	}
+
+	for i, column := range o.columnData {
+		t.columnData[i] = append(t.columnData[i], column[rowIndex])
+	}
+}
+
+// emptyCopy returns a table with the columns and layout of t but no rows
+// and no indexes
+func (t *table) emptyCopy() *table {
+	c := &table{
+		columns:     t.columns,
+		columnTypes: t.columnTypes,
+		typmods:     t.typmods,
+		constraints: t.constraints,
+		columnar:    t.columnar,
+
+		qualifiers:    t.qualifiers,
+		qualifiedOnly: t.qualifiedOnly,
+	}
+
+	if t.columnar {
+		c.columnData = make([][]MemoryCell, len(t.columns))
+	}
+
+	return c
+}
+
+// subset returns a copy of t holding only the given rows, in that order
+func (t *table) subset(rowIndexes []uint) *table {
+	c := t.emptyCopy()
+	for _, rowIndex := range rowIndexes {
+		c.appendRowFrom(t, rowIndex)
+	}
+
+	return c
+}
+
+// replaceRows swaps the rows of t for the rows of c, which must have
+// the same columns and layout
+func (t *table) replaceRows(c *table) {
+	t.rows = c.rows
+	t.columnData = c.columnData
+}
+
+// columnSlice returns the cells of a column for the rows from start to
+// end without copying them, which only columnar tables can do
+func (t *table) columnSlice(column int, start, end uint) ([]MemoryCell, bool) {
+	if !t.columnar {
+		return nil, false
+	}
+
+	return t.columnData[column][start:end], true
+}
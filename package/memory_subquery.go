@@ -0,0 +1,302 @@
+package pck
+
+// outerRow is the row of an enclosing query a subquery is evaluated for
+type outerRow struct {
+	sc       *scope
+	rowIndex uint
+
+	// A subquery is first compiled without a row to find the type of its
+	// result. It reads no rows then, and the columns of the enclosing
+	// query are NULL.
+	valid bool
+	// correlated is set once the subquery refers to a column of the
+	// enclosing query, otherwise its result is the same for every row
+	correlated bool
+}
+
+// describing reports whether a query is only compiled to find the
+// columns of its result, without reading any rows
+func (o *outerRow) describing() bool {
+	return o != nil && !o.valid
+}
+
+// column resolves a column of the enclosing query, which is constant
+// while the subquery runs. The enclosing query may be grouped, its
+// columns are then those it is grouped by.
+func (o *outerRow) column(lit Token) (compiledExpression, error) {
+	exp := expression{literal: &lit, kind: literalKind}
+	if o.sc.grouping != nil {
+		var err error
+		if exp, err = o.sc.grouping.rewrite(exp); err != nil {
+			return compiledExpression{}, err
+		}
+	}
+
+	c, err := o.sc.compileExpression(exp)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	o.correlated = true
+	value := MemoryCell{}
+	if o.valid {
+		if value, err = c.eval(o.rowIndex); err != nil {
+			return compiledExpression{}, err
+		}
+	}
+
+	return constantExpression(value, c.name, c.typ), nil
+}
+
+// derivedTable runs the subquery of a FROM item into a table
+func (mb *MemoryBackend) derivedTable(item fromItem, outer *outerRow) (*table, error) {
+	results, err := mb.query(item.subquery, outer)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ""
+	if item.alias != nil {
+		name = item.alias.value
+	}
+
+	t := &table{}
+	for _, column := range results.Columns {
+		t.addColumn(column.Name, column.Type, name, false)
+	}
+
+	for _, result := range results.Rows {
+		row := make([]MemoryCell, len(result))
+		for i, cell := range result {
+			row[i] = cell.(MemoryCell)
+		}
+
+		t.appendRow(row)
+	}
+
+	return t, nil
+}
+
+// valueSet holds the values of a subquery IN looks values up in. Values
+// are converted to typ when promote is set.
+type valueSet struct {
+	typ     ColumnType
+	promote bool
+	keys    map[string]bool
+	hasNull bool
+}
+
+// key encodes a value so values that are equal have the same key
+func (s *valueSet) key(value MemoryCell, from ColumnType) (string, error) {
+	if s.promote && from != s.typ {
+		var err error
+		if value, err = promoteOperand(value, from, s.typ); err != nil {
+			return "", err
+		}
+	}
+
+	// Intervals are equal when they span the same time, however they are
+	// written
+	if s.typ == IntervalType {
+		return value.AsInterval().totalMicroseconds().String(), nil
+	}
+
+	return groupKey([]MemoryCell{value}), nil
+}
+
+func (s *valueSet) add(value MemoryCell, from ColumnType) error {
+	if value.IsNull() {
+		s.hasNull = true
+		return nil
+	}
+
+	key, err := s.key(value, from)
+	if err != nil {
+		return err
+	}
+
+	s.keys[key] = true
+	return nil
+}
+
+// contains follows the rules of = for each value of the set: it returns
+// NULL rather than false when value or any value of the set is NULL
+func (s *valueSet) contains(value MemoryCell, from ColumnType) (MemoryCell, error) {
+	if len(s.keys) == 0 && !s.hasNull {
+		return falseMemoryCell, nil
+	}
+
+	if value.IsNull() {
+		return MemoryCell{}, nil
+	}
+
+	key, err := s.key(value, from)
+	if err != nil {
+		return MemoryCell{}, err
+	}
+
+	if s.keys[key] {
+		return trueMemoryCell, nil
+	}
+
+	if s.hasNull {
+		return MemoryCell{}, nil
+	}
+
+	return falseMemoryCell, nil
+}
+
+// compileSubquery compiles a subquery used as a value. It is run once
+// when first evaluated, and again for every row only if it refers to the
+// columns of the row.
+func (sc *scope) compileSubquery(sq subqueryExpression) (compiledExpression, error) {
+	if sc.mb == nil {
+		return compiledExpression{}, ErrInvalidCell
+	}
+
+	outer := &outerRow{sc: sc}
+	described, err := sc.mb.query(sq.slct, outer)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	if sq.kind != existsSubquery && len(described.Columns) != 1 {
+		return compiledExpression{}, ErrSubqueryColumns
+	}
+
+	// run returns the rows of the subquery for a row of sc.t
+	var results *Results
+	run := func(rowIndex uint) (*Results, error) {
+		if outer.correlated {
+			return sc.mb.query(sq.slct, &outerRow{sc: sc, rowIndex: rowIndex, valid: true})
+		}
+
+		if results == nil {
+			var err error
+			if results, err = sc.mb.query(sq.slct, nil); err != nil {
+				return nil, err
+			}
+		}
+
+		return results, nil
+	}
+
+	switch sq.kind {
+	case scalarSubquery:
+		return rowExpression(described.Columns[0].Name, described.Columns[0].Type, func(rowIndex uint) (MemoryCell, error) {
+			results, err := run(rowIndex)
+			if err != nil {
+				return MemoryCell{}, err
+			}
+
+			switch len(results.Rows) {
+			case 0:
+				return MemoryCell{}, nil
+			case 1:
+				return results.Rows[0][0].(MemoryCell), nil
+			default:
+				return MemoryCell{}, ErrSubqueryRows
+			}
+		}), nil
+	case existsSubquery:
+		return rowExpression("exists", BoolType, func(rowIndex uint) (MemoryCell, error) {
+			results, err := run(rowIndex)
+			if err != nil {
+				return MemoryCell{}, err
+			}
+
+			return boolToMemoryCell(len(results.Rows) > 0), nil
+		}), nil
+	default:
+		return sc.compileIn(sq, described.Columns[0].Type, run, outer)
+	}
+}
+
+// selectsNull reports whether the only column slct selects is an untyped
+// NULL
+func selectsNull(slct *SelectStatement) bool {
+	if slct.item == nil || len(*slct.item) != 1 {
+		return false
+	}
+
+	item := (*slct.item)[0]
+	return !item.Asterisk && item.Exp != nil && isNullLiteral(*item.Exp)
+}
+
+// compileIn compiles exp IN (subquery), which compares exp with the values
+// of the subquery the way = does
+func (sc *scope) compileIn(sq subqueryExpression, rt ColumnType, run func(uint) (*Results, error), outer *outerRow) (compiledExpression, error) {
+	operand, err := sc.compileExpression(*sq.exp)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	// An untyped NULL on either side has the type of the other, as with =
+	lt := operand.typ
+	if isNullLiteral(*sq.exp) {
+		lt = rt
+	} else if selectsNull(sq.slct) {
+		rt = lt
+	}
+
+	if operand.constant {
+		value, typ, err := untypedOperand(tokenFromSymbol(EqSymbol), *sq.exp, operand.value, lt, rt)
+		if err != nil {
+			return compiledExpression{}, err
+		}
+
+		operand, lt = constantExpression(value, operand.name, typ), typ
+	}
+
+	typ, promote := promotedType(lt, rt)
+	if !promote {
+		if !comparableTypes(lt, rt) {
+			return compiledExpression{}, ErrInvalidOperands
+		}
+
+		typ = lt
+	}
+
+	set := func(results *Results) (*valueSet, error) {
+		s := &valueSet{
+			typ:     typ,
+			promote: promote,
+			keys:    map[string]bool{},
+		}
+
+		for _, row := range results.Rows {
+			if err := s.add(row[0].(MemoryCell), rt); err != nil {
+				return nil, err
+			}
+		}
+
+		return s, nil
+	}
+
+	// The values of an uncorrelated subquery are collected once
+	var values *valueSet
+	return rowExpression("?column?", BoolType, func(rowIndex uint) (MemoryCell, error) {
+		value, err := operand.eval(rowIndex)
+		if err != nil {
+			return MemoryCell{}, err
+		}
+
+		s := values
+		if s == nil {
+			results, err := run(rowIndex)
+			if err != nil {
+				return MemoryCell{}, err
+			}
+
+			if s, err = set(results); err != nil {
+				return MemoryCell{}, err
+			}
+
+			if !outer.correlated {
+				values = s
+			}
+		}
+
+		return s.contains(value, lt)
+	}), nil
+}
@@ -0,0 +1,2294 @@
+package pck
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const benchmarkRows = 10000
+
+// byteCell is the previous MemoryCell, a value encoded as bytes and
+// decoded on every access. It is kept here to measure against.
+type byteCell []byte
+
+func (bc byteCell) AsInt64() int64 {
+	switch len(bc) {
+	case 2:
+		return int64(int16(binary.BigEndian.Uint16(bc)))
+	case 4:
+		return int64(int32(binary.BigEndian.Uint32(bc)))
+	default:
+		return int64(binary.BigEndian.Uint64(bc))
+	}
+}
+
+func (bc byteCell) AsNumeric() *big.Rat {
+	r, _ := new(big.Rat).SetString(string(bc))
+	return r
+}
+
+func (bc byteCell) AsText() string {
+	return string(bc)
+}
+
+func (bc byteCell) AsBool() bool {
+	return len(bc) != 0 && bc[0] != 0
+}
+
+func (bc byteCell) IsNull() bool {
+	return bc == nil
+}
+
+// benchmarkTable builds rows of an integer, a bigint, a numeric, a text
+// and a boolean, every tenth integer being NULL
+func benchmarkTable() ([][]MemoryCell, [][]byteCell) {
+	cells := [][]MemoryCell{}
+	bytes := [][]byteCell{}
+	for i := 0; i < benchmarkRows; i++ {
+		numeric := fmt.Sprintf("%d.%02d", i, i%100)
+		text := "row" + strconv.Itoa(i)
+
+		row := []MemoryCell{
+			encodeInt(int64(i), IntType),
+			encodeInt(int64(i)*1000, BigIntType),
+			numericCell(numeric),
+			textCell(text),
+			boolToMemoryCell(i%2 == 0),
+		}
+
+		integer := make(byteCell, 4)
+		binary.BigEndian.PutUint32(integer, uint32(i))
+		bigint := make(byteCell, 8)
+		binary.BigEndian.PutUint64(bigint, uint64(i)*1000)
+		boolean := byteCell{0}
+		if i%2 == 0 {
+			boolean = byteCell{1}
+		}
+
+		byteRow := []byteCell{integer, bigint, byteCell(numeric), byteCell(text), boolean}
+
+		if i%10 == 0 {
+			row[0] = MemoryCell{}
+			byteRow[0] = nil
+		}
+
+		cells = append(cells, row)
+		bytes = append(bytes, byteRow)
+	}
+
+	return cells, bytes
+}
+
+func BenchmarkScanIntegers(b *testing.B) {
+	cells, bytes := benchmarkTable()
+
+	b.Run("tagged", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			sum := int64(0)
+			for _, row := range cells {
+				if !row[0].IsNull() && row[4].AsBool() {
+					sum += row[0].AsInt64() + row[1].AsInt64()
+				}
+			}
+		}
+	})
+
+	b.Run("bytes", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			sum := int64(0)
+			for _, row := range bytes {
+				if !row[0].IsNull() && row[4].AsBool() {
+					sum += row[0].AsInt64() + row[1].AsInt64()
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkScanNumerics(b *testing.B) {
+	cells, bytes := benchmarkTable()
+	bound := numericCell("5000.50")
+
+	b.Run("tagged", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for _, row := range cells {
+				compareCells(row[2], bound, NumericType)
+			}
+		}
+	})
+
+	b.Run("bytes", func(b *testing.B) {
+		byteBound := byteCell("5000.50")
+		for n := 0; n < b.N; n++ {
+			for _, row := range bytes {
+				row[2].AsNumeric().Cmp(byteBound.AsNumeric())
+			}
+		}
+	})
+}
+
+func BenchmarkScanText(b *testing.B) {
+	cells, bytes := benchmarkTable()
+
+	b.Run("tagged", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for _, row := range cells {
+				_ = row[3].AsText() == "row5000"
+			}
+		}
+	})
+
+	b.Run("bytes", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for _, row := range bytes {
+				_ = row[3].AsText() == "row5000"
+			}
+		}
+	})
+}
+
+func BenchmarkSelectFullScan(b *testing.B) {
+	cells, _ := benchmarkTable()
+	heap := &table{
+		columns:     []string{"i", "b", "n", "t", "f"},
+		columnTypes: []ColumnType{IntType, BigIntType, NumericType, TextType, BoolType},
+		typmods:     make([]numericTypmod, 5),
+		constraints: make([]columnConstraints, 5),
+		rows:        cells,
+	}
+
+	columnar := heap.emptyCopy()
+	columnar.columnar = true
+	columnar.columnData = make([][]MemoryCell, len(heap.columns))
+	for i := uint(0); i < heap.rowCount(); i++ {
+		columnar.appendRow(heap.row(i))
+	}
+
+	for _, query := range []string{
+		"SELECT i, b + 1, t FROM bench WHERE f AND n > 5000.5;",
+		"SELECT i FROM bench WHERE i >= 100 AND b < 9000000;",
+	} {
+		ast, err := Parse(query)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for name, t := range map[string]*table{"heap": heap, "columnar": columnar} {
+			mb := NewMemoryBackend()
+			mb.tables["bench"] = t
+
+			b.Run(name+"/"+query, func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					if _, err := mb.Select(ast.Statements[0].SelectStatement); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// walkCell is the previous evaluator, which walked the expression tree
+// for every row, looking columns up by name and parsing literals each
+// time. It only handles the columns, literals and binary expressions
+// BenchmarkEvaluate uses, and is kept here to measure against.
+func walkCell(t *table, rowIndex uint, exp expression) (MemoryCell, ColumnType, error) {
+	if exp.kind == literalKind {
+		lit := exp.literal
+		switch lit.kind {
+		case identifierKind:
+			for i, column := range t.columns {
+				if column == lit.value {
+					return t.cell(rowIndex, i), t.columnTypes[i], nil
+				}
+			}
+
+			return MemoryCell{}, 0, ErrColumnDoesNotExist
+		case numericKind:
+			return parseNumericLiteral(lit.value)
+		default:
+			return MemoryCell{}, 0, ErrInvalidCell
+		}
+	}
+
+	if exp.kind != binaryKind {
+		return MemoryCell{}, 0, ErrInvalidCell
+	}
+
+	bexp := exp.binary
+	l, lt, err := walkCell(t, rowIndex, bexp.a)
+	if err != nil {
+		return MemoryCell{}, 0, err
+	}
+
+	r, rt, err := walkCell(t, rowIndex, bexp.b)
+	if err != nil {
+		return MemoryCell{}, 0, err
+	}
+
+	if typ, ok := promotedType(lt, rt); ok {
+		if l, err = promoteOperand(l, lt, typ); err != nil {
+			return MemoryCell{}, 0, err
+		}
+
+		if r, err = promoteOperand(r, rt, typ); err != nil {
+			return MemoryCell{}, 0, err
+		}
+
+		lt, rt = typ, typ
+	}
+
+	return applyBinaryOperator(*bexp, l, r, lt, rt)
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	cells, _ := benchmarkTable()
+	t := &table{
+		columns:     []string{"i", "b", "n", "t", "f"},
+		columnTypes: []ColumnType{IntType, BigIntType, NumericType, TextType, BoolType},
+		typmods:     make([]numericTypmod, 5),
+		constraints: make([]columnConstraints, 5),
+		rows:        cells,
+	}
+
+	ast, err := Parse("SELECT i FROM bench WHERE f AND b > 1000 AND n < 9000.5;")
+	if err != nil {
+		b.Fatal(err)
+	}
+	where := *ast.Statements[0].SelectStatement.where
+	sc := &scope{t: t}
+
+	b.Run("tree walking", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for i := uint(0); i < t.rowCount(); i++ {
+				if _, _, err := walkCell(t, i, where); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("compiled", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			c, err := sc.compileExpression(where)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			for i := uint(0); i < t.rowCount(); i++ {
+				if _, err := c.eval(i); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if _, err := sc.filterRows(&where, -1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// run parses source and runs its statements in order on mb, stopping at
+// the first that fails. Returns the results of the last SELECT.
+func run(mb *MemoryBackend, source string) (*Results, error) {
+	ast, err := Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var results *Results
+	for _, stmt := range ast.Statements {
+		switch stmt.Kind {
+		case CreateTableKind:
+			err = mb.CreateTable(stmt.CreateTableStatement)
+		case CreateIndexKind:
+			err = mb.CreateIndex(stmt.CreateIndexStatement)
+		case DropTableKind:
+			err = mb.DropTable(stmt.DropTableStatement)
+		case DropIndexKind:
+			err = mb.DropIndex(stmt.DropIndexStatement)
+		case InsertKind:
+			err = mb.Insert(stmt.InsertStatement)
+		case UpdateKind:
+			_, err = mb.Update(stmt.UpdateStatement)
+		case DeleteKind:
+			_, err = mb.Delete(stmt.DeleteStatement)
+		case SelectKind:
+			results, err = mb.Select(stmt.SelectStatement)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// resultsText renders the cells of results as text, NULLs as empty
+// strings
+func resultsText(results *Results) [][]string {
+	values := [][]string{}
+	for _, row := range results.Rows {
+		value := []string{}
+		for i, cell := range row {
+			if cell.IsNull() {
+				value = append(value, "")
+				continue
+			}
+
+			switch results.Columns[i].Type {
+			case SmallIntType, IntType, BigIntType:
+				value = append(value, strconv.FormatInt(cell.AsInt64(), 10))
+			case RealType, DoubleType:
+				value = append(value, formatFloat(cell.AsFloat64(), results.Columns[i].Type))
+			case DateType, TimeType, TimestampType, TimestampTzType, IntervalType:
+				value = append(value, formatTemporal(cell, results.Columns[i].Type))
+			case BoolType:
+				value = append(value, strconv.FormatBool(cell.AsBool()))
+			default:
+				value = append(value, cell.AsText())
+			}
+		}
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// statementTest runs stmts on a backend set up by its test, expecting
+// them to fail with err, then compares the rows of query
+type statementTest struct {
+	stmts string
+	err   error
+	query string
+	rows  [][]string
+}
+
+func runStatementTests(t *testing.T, setup string, tests []statementTest) {
+	for _, test := range tests {
+		mb := NewMemoryBackend()
+		_, err := run(mb, setup)
+		assert.Nil(t, err, setup)
+
+		_, err = run(mb, test.stmts)
+		assert.ErrorIs(t, err, test.err, test.stmts)
+
+		if test.query != "" {
+			results, err := run(mb, test.query)
+			if assert.Nil(t, err, test.query) {
+				assert.Equal(t, test.rows, resultsText(results), test.stmts)
+			}
+		}
+	}
+}
+
+func TestCreateIndex(t *testing.T) {
+	setup := `CREATE TABLE users (id INT, name TEXT);
+	INSERT INTO users VALUES (1, 'ann');
+	INSERT INTO users VALUES (2, 'bob');
+	INSERT INTO users VALUES (2, 'cat');`
+
+	tests := []statementTest{
+		{
+			stmts: "CREATE INDEX users_id ON users (id);",
+			query: "SELECT name FROM users WHERE id = 2;",
+			rows:  [][]string{{"bob"}, {"cat"}},
+		},
+		{
+			stmts: "CREATE INDEX users_id ON users (id); INSERT INTO users VALUES (0, 'dan');",
+			query: "SELECT name FROM users WHERE id = 0;",
+			rows:  [][]string{{"dan"}},
+		},
+		{
+			stmts: "CREATE INDEX users_name ON users (name);",
+			query: "SELECT id FROM users WHERE name = 'cat' AND id = 2;",
+			rows:  [][]string{{"2"}},
+		},
+		{
+			stmts: "CREATE UNIQUE INDEX users_name ON users (name); INSERT INTO users VALUES (5, 'ann');",
+			err:   ErrViolatesUniqueConstraint,
+			query: "SELECT id FROM users WHERE name = 'ann';",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			stmts: "CREATE UNIQUE INDEX users_id ON users (id);",
+			err:   ErrViolatesUniqueConstraint,
+		},
+		{
+			stmts: "CREATE INDEX users_id ON users (id); CREATE INDEX users_id ON users (name);",
+			err:   ErrIndexAlreadyExists,
+		},
+		{
+			stmts: "CREATE INDEX users_x ON users (x);",
+			err:   ErrColumnDoesNotExist,
+		},
+		{
+			stmts: "CREATE INDEX users_id ON nope (id);",
+			err:   ErrTableDoesNotExist,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestIndexLookup(t *testing.T) {
+	mb := NewMemoryBackend()
+	_, err := run(mb, `CREATE TABLE users (id INT, name TEXT);
+	INSERT INTO users VALUES (1, 'ann');
+	INSERT INTO users VALUES (2, 'bob');
+	INSERT INTO users VALUES (3, 'cat');
+	CREATE INDEX users_id ON users (id);`)
+	assert.Nil(t, err)
+
+	users := mb.tables["users"]
+	tests := []struct {
+		where string
+		rows  int
+		ok    bool
+	}{
+		{"id = 2", 1, true},
+		{"2 = id", 1, true},
+		{"id = 2 AND name = 'bob'", 1, true},
+		{"id >= 2", 2, true},
+		{"2 > id", 1, true},
+		{"id > 1 AND id < 3", 1, true},
+		{"id = 4", 0, true},
+		{"name = 'bob'", 0, false},
+		{"id = 2 OR id = 3", 0, false},
+	}
+
+	for _, test := range tests {
+		ast, err := Parse("SELECT id FROM users WHERE " + test.where + ";")
+		if !assert.Nil(t, err, test.where) {
+			continue
+		}
+
+		subset, ok := users.indexes[0].newTableFromSubset(users, *ast.Statements[0].SelectStatement.where)
+		assert.Equal(t, test.ok, ok, test.where)
+		if ok {
+			assert.Equal(t, test.rows, len(subset.rows), test.where)
+		}
+	}
+}
+
+func TestColumnConstraints(t *testing.T) {
+	setup := `CREATE TABLE users (id INT PRIMARY KEY, name TEXT NOT NULL, email TEXT UNIQUE, logins INT DEFAULT 1 + 2);
+	INSERT INTO users (id, name) VALUES (1, 'ann');`
+
+	tests := []statementTest{
+		{
+			stmts: "INSERT INTO users (id, name, email) VALUES (2, 'bob', 'bob@x');",
+			query: "SELECT id, name, email, logins FROM users;",
+			rows:  [][]string{{"1", "ann", "", "3"}, {"2", "bob", "bob@x", "3"}},
+		},
+		{
+			stmts: "INSERT INTO users (logins, name, id) VALUES (7, 'bob', 2);",
+			query: "SELECT id, name, logins FROM users WHERE id = 2;",
+			rows:  [][]string{{"2", "bob", "7"}},
+		},
+		{
+			stmts: "INSERT INTO users VALUES (2, 'bob', NULL, NULL);",
+			query: "SELECT logins FROM users WHERE id = 2;",
+			rows:  [][]string{{""}},
+		},
+		{
+			stmts: "INSERT INTO users (id, name) VALUES (1, 'bob');",
+			err:   ErrViolatesUniqueConstraint,
+			query: "SELECT name FROM users;",
+			rows:  [][]string{{"ann"}},
+		},
+		{
+			stmts: "INSERT INTO users (id, name) VALUES (NULL, 'bob');",
+			err:   ErrViolatesNotNullConstraint,
+		},
+		{
+			stmts: "INSERT INTO users (id) VALUES (2);",
+			err:   ErrViolatesNotNullConstraint,
+		},
+		{
+			stmts: "INSERT INTO users (id, nope) VALUES (2, 'bob');",
+			err:   ErrColumnDoesNotExist,
+		},
+		{
+			stmts: "INSERT INTO users (id, name, id) VALUES (2, 'bob', 3);",
+			err:   ErrColumnAlreadyExists,
+			query: "SELECT id FROM users;",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			stmts: `INSERT INTO users (id, name, email) VALUES (2, 'bob', NULL);
+			INSERT INTO users (id, name, email) VALUES (3, 'cat', NULL);
+			INSERT INTO users (id, name, email) VALUES (4, 'dan', 'd@x');
+			INSERT INTO users (id, name, email) VALUES (5, 'eve', 'd@x');`,
+			err:   ErrViolatesUniqueConstraint,
+			query: "SELECT id FROM users;",
+			rows:  [][]string{{"1"}, {"2"}, {"3"}, {"4"}},
+		},
+		{
+			stmts: "CREATE TABLE pairs (a INT PRIMARY KEY, b INT PRIMARY KEY);",
+			err:   ErrPrimaryKeyAlreadyExists,
+		},
+		{
+			stmts: "CREATE TABLE t (n NUMERIC(4,1) DEFAULT '2.25', d DATE DEFAULT now(), s TEXT NOT NULL DEFAULT NULL); INSERT INTO t (s) VALUES ('x');",
+			query: "SELECT n, d IS NOT NULL, s FROM t;",
+			rows:  [][]string{{"2.3", "true", "x"}},
+		},
+		{
+			stmts: "CREATE TABLE t (n INT DEFAULT 'abc');",
+			err:   ErrInvalidDatatype,
+		},
+		{
+			stmts: "CREATE TABLE t (n INT DEFAULT true);",
+			err:   ErrInvalidDatatype,
+		},
+		{
+			stmts: "CREATE TABLE t (n SMALLINT DEFAULT 40000);",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			stmts: "CREATE TABLE t (n INT DEFAULT now());",
+			err:   ErrInvalidDatatype,
+		},
+		{
+			stmts: "CREATE TABLE t (n INT DEFAULT nope);",
+			err:   ErrColumnDoesNotExist,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestDrop(t *testing.T) {
+	setup := `CREATE TABLE users (id INT PRIMARY KEY, email TEXT UNIQUE);
+	CREATE INDEX users_email_idx ON users (email);
+	INSERT INTO users VALUES (1, 'a@x');`
+
+	tests := []statementTest{
+		{
+			stmts: "DROP TABLE users; CREATE TABLE users (name TEXT);",
+			query: "SELECT * FROM users;",
+			rows:  [][]string{},
+		},
+		{
+			stmts: "DROP TABLE users; SELECT * FROM users;",
+			err:   ErrTableDoesNotExist,
+		},
+		{
+			stmts: "DROP TABLE users; CREATE INDEX users_email_idx ON users (email);",
+			err:   ErrTableDoesNotExist,
+		},
+		{
+			stmts: "DROP TABLE users; CREATE TABLE users (id INT PRIMARY KEY, email TEXT); INSERT INTO users VALUES (1, 'a@x');",
+			query: "SELECT email FROM users WHERE id = 1;",
+			rows:  [][]string{{"a@x"}},
+		},
+		{
+			stmts: "DROP TABLE nope;",
+			err:   ErrTableDoesNotExist,
+		},
+		{
+			stmts: "DROP TABLE IF EXISTS nope;",
+		},
+		{
+			stmts: "DROP INDEX users_email_idx; CREATE INDEX users_email_idx ON users (email);",
+			query: "SELECT id FROM users WHERE email = 'a@x';",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			stmts: "DROP INDEX users_email_idx; DROP INDEX users_email_idx;",
+			err:   ErrIndexDoesNotExist,
+		},
+		{
+			stmts: "DROP INDEX IF EXISTS nope;",
+		},
+		{
+			stmts: "DROP INDEX users_pkey;",
+			err:   ErrIndexRequiredByConstraint,
+		},
+		{
+			stmts: "DROP INDEX IF EXISTS users_email_key;",
+			err:   ErrIndexRequiredByConstraint,
+			query: "SELECT id FROM users WHERE email = 'a@x';",
+			rows:  [][]string{{"1"}},
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestCreateTable(t *testing.T) {
+	setup := `CREATE TABLE users (id INT);
+	INSERT INTO users VALUES (1);`
+
+	tests := []statementTest{
+		{
+			stmts: "CREATE TABLE users (id INT, name TEXT);",
+			err:   ErrTableAlreadyExists,
+			query: "SELECT * FROM users;",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			stmts: "CREATE TABLE IF NOT EXISTS users (name TEXT); INSERT INTO users VALUES (2);",
+			query: "SELECT * FROM users;",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			stmts: "CREATE TABLE pairs (a INT, b TEXT, a TEXT);",
+			err:   ErrColumnAlreadyExists,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestUpdate(t *testing.T) {
+	setup := `CREATE TABLE users (id INT PRIMARY KEY, name TEXT NOT NULL, score INT);
+	INSERT INTO users VALUES (1, 'ann', 10);
+	INSERT INTO users VALUES (2, 'bob', 20);
+	INSERT INTO users VALUES (3, 'cat', 20);`
+
+	tests := []statementTest{
+		{
+			stmts: "UPDATE users SET score = score + 1 WHERE score = 20;",
+			query: "SELECT id, score FROM users;",
+			rows:  [][]string{{"1", "10"}, {"2", "21"}, {"3", "21"}},
+		},
+		{
+			stmts: "UPDATE users SET name = 'x';",
+			query: "SELECT name FROM users;",
+			rows:  [][]string{{"x"}, {"x"}, {"x"}},
+		},
+		// Every SET value sees the row as it was
+		{
+			stmts: "UPDATE users SET score = id, id = score WHERE id = 1;",
+			query: "SELECT id, score FROM users WHERE id = 10;",
+			rows:  [][]string{{"10", "1"}},
+		},
+		{
+			stmts: "UPDATE users SET id = id + 1;",
+			query: "SELECT id, name FROM users WHERE id = 3;",
+			rows:  [][]string{{"3", "bob"}},
+		},
+		{
+			stmts: "UPDATE users SET id = 1 WHERE id = 2;",
+			err:   ErrViolatesUniqueConstraint,
+			query: "SELECT id FROM users;",
+			rows:  [][]string{{"1"}, {"2"}, {"3"}},
+		},
+		{
+			stmts: "UPDATE users SET name = NULL WHERE id = 3;",
+			err:   ErrViolatesNotNullConstraint,
+		},
+		{
+			stmts: "UPDATE users SET rank = 1;",
+			err:   ErrColumnDoesNotExist,
+		},
+		{
+			stmts: "UPDATE nope SET id = 1;",
+			err:   ErrTableDoesNotExist,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+
+	mb := NewMemoryBackend()
+	_, err := run(mb, setup)
+	assert.Nil(t, err)
+
+	conn := &Conn{mb}
+	for _, test := range []struct {
+		stmt     string
+		affected int64
+	}{
+		{"UPDATE users SET score = 0 WHERE score = 20;", 2},
+		{"UPDATE users SET score = 0 WHERE false;", 0},
+		{"UPDATE users SET score = 1;", 3},
+	} {
+		result, err := conn.Exec(test.stmt, nil)
+		if assert.Nil(t, err, test.stmt) {
+			affected, _ := result.RowsAffected()
+			assert.Equal(t, test.affected, affected, test.stmt)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	setup := `CREATE TABLE users (id INT PRIMARY KEY, name TEXT, score INT);
+	CREATE INDEX users_score ON users (score);
+	INSERT INTO users VALUES (1, 'ann', 10);
+	INSERT INTO users VALUES (2, 'bob', 20);
+	INSERT INTO users VALUES (3, 'cat', 30);`
+
+	tests := []statementTest{
+		{
+			stmts: "DELETE FROM users WHERE score = 20;",
+			query: "SELECT id FROM users;",
+			rows:  [][]string{{"1"}, {"3"}},
+		},
+		{
+			stmts: "DELETE FROM users WHERE id = 1 OR name = 'cat';",
+			query: "SELECT id FROM users;",
+			rows:  [][]string{{"2"}},
+		},
+		{
+			stmts: "DELETE FROM users;",
+			query: "SELECT id FROM users;",
+			rows:  [][]string{},
+		},
+		// The indexes only find the rows left
+		{
+			stmts: "DELETE FROM users WHERE id = 1; INSERT INTO users VALUES (1, 'dan', 20);",
+			query: "SELECT name FROM users WHERE score = 20;",
+			rows:  [][]string{{"bob"}, {"dan"}},
+		},
+		{
+			stmts: "DELETE FROM nope;",
+			err:   ErrTableDoesNotExist,
+		},
+		{
+			stmts: "DELETE FROM users WHERE rank = 1;",
+			err:   ErrColumnDoesNotExist,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+
+	mb := NewMemoryBackend()
+	_, err := run(mb, setup)
+	assert.Nil(t, err)
+
+	conn := &Conn{mb}
+	result, err := conn.Exec("DELETE FROM users WHERE id <> 2;", nil)
+	if assert.Nil(t, err) {
+		affected, _ := result.RowsAffected()
+		assert.Equal(t, int64(2), affected)
+	}
+}
+
+func TestLimitOffset(t *testing.T) {
+	setup := `CREATE TABLE users (id INT, name TEXT);
+	INSERT INTO users VALUES (1, 'ann');
+	INSERT INTO users VALUES (2, 'bob');
+	INSERT INTO users VALUES (3, 'cat');
+	INSERT INTO users VALUES (4, 'dan');`
+
+	tests := []statementTest{
+		{
+			query: "SELECT id FROM users LIMIT 2;",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+		{
+			query: "SELECT id FROM users LIMIT 2 OFFSET 3;",
+			rows:  [][]string{{"4"}},
+		},
+		{
+			query: "SELECT id FROM users OFFSET 1 + 1;",
+			rows:  [][]string{{"3"}, {"4"}},
+		},
+		{
+			query: "SELECT id FROM users WHERE name <> 'ann' LIMIT 0;",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT id FROM users WHERE name <> 'bob' LIMIT 1 OFFSET 1;",
+			rows:  [][]string{{"3"}},
+		},
+		{
+			query: "SELECT id FROM users OFFSET 10;",
+			rows:  [][]string{},
+		},
+		{
+			stmts: "SELECT id FROM users LIMIT 'a';",
+			err:   ErrInvalidLimit,
+		},
+		{
+			stmts: "SELECT id FROM users LIMIT id;",
+			err:   ErrColumnDoesNotExist,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestOrderBy(t *testing.T) {
+	setup := `CREATE TABLE users (id INT, name TEXT, team INT);
+	INSERT INTO users VALUES (1, 'ann', 20);
+	INSERT INTO users VALUES (2, 'bob', NULL);
+	INSERT INTO users VALUES (3, 'cat', 10);
+	INSERT INTO users VALUES (4, 'dan', 20);`
+
+	tests := []statementTest{
+		{
+			query: "SELECT id FROM users ORDER BY name DESC;",
+			rows:  [][]string{{"4"}, {"3"}, {"2"}, {"1"}},
+		},
+		// NULLs sort last ascending and first descending
+		{
+			query: "SELECT id FROM users ORDER BY team, id DESC;",
+			rows:  [][]string{{"3"}, {"4"}, {"1"}, {"2"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY team DESC, id;",
+			rows:  [][]string{{"2"}, {"1"}, {"4"}, {"3"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY team ASC NULLS FIRST, id;",
+			rows:  [][]string{{"2"}, {"3"}, {"1"}, {"4"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY team DESC NULLS LAST, id;",
+			rows:  [][]string{{"1"}, {"4"}, {"3"}, {"2"}},
+		},
+		{
+			query: "SELECT id, 'x' || name AS label FROM users ORDER BY label DESC;",
+			rows:  [][]string{{"4", "xdan"}, {"3", "xcat"}, {"2", "xbob"}, {"1", "xann"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY name = 'cat', id DESC;",
+			rows:  [][]string{{"4"}, {"2"}, {"1"}, {"3"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY id DESC LIMIT 2 OFFSET 1;",
+			rows:  [][]string{{"3"}, {"2"}},
+		},
+		// Integers give the position of a select item
+		{
+			query: "SELECT name, team FROM users ORDER BY 2 DESC, 1;",
+			rows:  [][]string{{"bob", ""}, {"ann", "20"}, {"dan", "20"}, {"cat", "10"}},
+		},
+		{
+			query: "SELECT * FROM users WHERE team = 20 ORDER BY 1 DESC;",
+			rows:  [][]string{{"4", "dan", "20"}, {"1", "ann", "20"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE id = (SELECT id FROM users ORDER BY 1 DESC LIMIT 1);",
+			rows:  [][]string{{"dan"}},
+		},
+		{
+			stmts: "SELECT id FROM users ORDER BY rank;",
+			err:   ErrColumnDoesNotExist,
+		},
+		{
+			stmts: "SELECT id, name FROM users ORDER BY 3;",
+			err:   ErrInvalidSelectItem,
+		},
+		{
+			stmts: "SELECT id FROM users ORDER BY 0;",
+			err:   ErrInvalidSelectItem,
+		},
+		{
+			stmts: "SELECT id FROM users ORDER BY 1.5;",
+			err:   ErrInvalidSelectItem,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestRangeComparisons(t *testing.T) {
+	setup := `CREATE TABLE users (id INT, name TEXT, team INT);
+	CREATE INDEX users_id ON users (id);
+	INSERT INTO users VALUES (1, 'ann', 10);
+	INSERT INTO users VALUES (2, 'bob', NULL);
+	INSERT INTO users VALUES (3, 'cat', 30);`
+
+	tests := []statementTest{
+		{
+			query: "SELECT id < 2, id <= 2, id > 2, id >= 2 FROM users WHERE id = 2;",
+			rows:  [][]string{{"false", "true", "false", "true"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE id > 1 AND id <= 2;",
+			rows:  [][]string{{"bob"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE 2 < id OR id < 2;",
+			rows:  [][]string{{"ann"}, {"cat"}},
+		},
+		{
+			query: "SELECT id FROM users WHERE name >= 'b';",
+			rows:  [][]string{{"2"}, {"3"}},
+		},
+		// NULLs compare false
+		{
+			query: "SELECT id FROM users WHERE team < 40;",
+			rows:  [][]string{{"1"}, {"3"}},
+		},
+		{
+			query: "SELECT id FROM users ORDER BY id >= 2, id DESC;",
+			rows:  [][]string{{"1"}, {"3"}, {"2"}},
+		},
+		{
+			stmts: "SELECT id FROM users WHERE name > 1;",
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestArithmetic(t *testing.T) {
+	setup := `CREATE TABLE nums (i INT, z INT);
+	INSERT INTO nums VALUES (7, 0);`
+
+	tests := []statementTest{
+		{
+			query: "SELECT 7 - 10, 6 * 7, 7 / 2, -7 / 2, 7 % 3, -7 % 3;",
+			rows:  [][]string{{"-3", "42", "3", "-3", "1", "-1"}},
+		},
+		{
+			query: "SELECT 2 + 3 * 4, (2 + 3) * 4, 10 - 4 - 3, 12 / 3 / 2;",
+			rows:  [][]string{{"14", "20", "3", "2"}},
+		},
+		{
+			query: "SELECT -(2 + 3), -i, -(-i), i - -1 FROM nums;",
+			rows:  [][]string{{"-5", "-7", "7", "8"}},
+		},
+		// Negating a negative literal folds back to a positive one
+		{
+			query: "SELECT - -4, - - -4, -(-4), -1 - -9223372036854775807;",
+			rows:  [][]string{{"4", "-4", "4", "9223372036854775806"}},
+		},
+		{
+			query: "SELECT i FROM nums WHERE i * 2 - 4 > 9 AND i % 2 = 1;",
+			rows:  [][]string{{"7"}},
+		},
+		{
+			stmts: "SELECT i / z FROM nums;",
+			err:   ErrDivisionByZero,
+		},
+		{
+			stmts: "SELECT i % z FROM nums;",
+			err:   ErrDivisionByZero,
+		},
+		{
+			stmts: "SELECT 'a' - 1;",
+			err:   ErrInvalidOperands,
+		},
+		{
+			stmts: "SELECT -'a';",
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestNullLogic(t *testing.T) {
+	setup := `CREATE TABLE users (id INT, team INT);
+	INSERT INTO users VALUES (1, 10);
+	INSERT INTO users VALUES (2, NULL);`
+
+	tests := []statementTest{
+		{
+			query: "SELECT NULL AND false, NULL AND true, NULL OR true, NULL OR false, NOT NULL;",
+			rows:  [][]string{{"false", "", "true", "", ""}},
+		},
+		{
+			query: "SELECT NULL = NULL, NULL IS NULL, 1 IS NOT NULL, NULL IS TRUE, NULL IS NOT FALSE;",
+			rows:  [][]string{{"", "true", "true", "false", "true"}},
+		},
+		{
+			query: "SELECT NULL + 1, NULL || 'a', NULL / 0;",
+			rows:  [][]string{{"", "", ""}},
+		},
+		{
+			query: "SELECT id, team = 10, team <> 10, team + 1 FROM users;",
+			rows:  [][]string{{"1", "true", "false", "11"}, {"2", "", "", ""}},
+		},
+		{
+			query: "SELECT id FROM users WHERE team <> 10;",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT id FROM users WHERE NOT (team = 10);",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT id FROM users WHERE team IS NULL OR team > 10;",
+			rows:  [][]string{{"2"}},
+		},
+		// The right side of AND and OR is only evaluated for the rows
+		// the left side doesn't decide
+		{
+			stmts: "INSERT INTO users VALUES (3, 0);",
+			query: "SELECT id FROM users WHERE team <> 0 AND 20 / team > 1;",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			stmts: "INSERT INTO users VALUES (3, 0);",
+			query: "SELECT id, team = 0 OR 20 / team > 1 FROM users;",
+			rows:  [][]string{{"1", "true"}, {"2", ""}, {"3", "true"}},
+		},
+		{
+			stmts: "INSERT INTO users VALUES (3, 0); UPDATE users SET id = 4 WHERE team <> 0 AND 20 / team > 1;",
+			query: "SELECT id, team FROM users;",
+			rows:  [][]string{{"4", "10"}, {"2", ""}, {"3", "0"}},
+		},
+		{
+			stmts: "INSERT INTO users VALUES (3, 0); DELETE FROM users WHERE team = 0 OR 20 / team > 1;",
+			query: "SELECT id, team FROM users;",
+			rows:  [][]string{{"2", ""}},
+		},
+		{
+			stmts: "INSERT INTO users VALUES (3, 0); SELECT id FROM users WHERE team = 0 AND 20 / team > 1;",
+			err:   ErrDivisionByZero,
+		},
+		// Rows whose condition is NULL are neither updated nor deleted
+		{
+			stmts: "UPDATE users SET id = 0 WHERE team < 20; DELETE FROM users WHERE NOT (team = 10);",
+			query: "SELECT id, team FROM users;",
+			rows:  [][]string{{"0", "10"}, {"2", ""}},
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestBoolean(t *testing.T) {
+	setup := `CREATE TABLE flags (id INT, a BOOLEAN, b BOOL);
+	INSERT INTO flags VALUES (1, true, false);
+	INSERT INTO flags VALUES (2, NULL, true);
+	INSERT INTO flags VALUES (3, false, true);`
+
+	tests := []statementTest{
+		{
+			query: "SELECT id, a, b, a AND b, a OR b, NOT a, a = b FROM flags;",
+			rows: [][]string{
+				{"1", "true", "false", "false", "true", "false", "false"},
+				{"2", "", "true", "", "true", "", ""},
+				{"3", "false", "true", "false", "true", "true", "false"},
+			},
+		},
+		{
+			query: "SELECT id FROM flags WHERE a IS NOT TRUE;",
+			rows:  [][]string{{"2"}, {"3"}},
+		},
+		{
+			query: "SELECT id FROM flags WHERE b;",
+			rows:  [][]string{{"2"}, {"3"}},
+		},
+		{
+			query: "SELECT id FROM flags ORDER BY a, id;",
+			rows:  [][]string{{"3"}, {"1"}, {"2"}},
+		},
+		{
+			stmts: "UPDATE flags SET a = NOT b WHERE a IS NULL;",
+			query: "SELECT a FROM flags WHERE id = 2;",
+			rows:  [][]string{{"false"}},
+		},
+		{
+			stmts: "INSERT INTO flags VALUES (4, 1, true);",
+			err:   ErrInvalidDatatype,
+		},
+		{
+			stmts: "UPDATE flags SET b = 'maybe';",
+			err:   ErrInvalidDatatype,
+		},
+		{
+			stmts: "SELECT 1 AND true;",
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestInsertTypes(t *testing.T) {
+	setup := "CREATE TABLE items (i INT, t TEXT, b BOOLEAN);"
+
+	tests := []statementTest{
+		{
+			stmts: "INSERT INTO items VALUES ('12', 'a', 'yes');",
+			query: "SELECT * FROM items;",
+			rows:  [][]string{{"12", "a", "true"}},
+		},
+		{
+			stmts: "INSERT INTO items VALUES (' 7 ', 5, 'off');",
+			query: "SELECT * FROM items;",
+			rows:  [][]string{{"7", "5", "false"}},
+		},
+		{
+			stmts: "INSERT INTO items VALUES (1 + 2, 'a' || 'b', 1 = 1);",
+			query: "SELECT * FROM items;",
+			rows:  [][]string{{"3", "ab", "true"}},
+		},
+		{
+			stmts: "INSERT INTO items (b, i) VALUES (NULL, NULL);",
+			query: "SELECT * FROM items;",
+			rows:  [][]string{{"", "", ""}},
+		},
+		{
+			stmts: "INSERT INTO items VALUES ('x', 'a', NULL);",
+			err:   ErrInvalidDatatype,
+		},
+		{
+			stmts: "INSERT INTO items VALUES (1, 'a', 1);",
+			err:   ErrInvalidDatatype,
+		},
+		{
+			stmts: "INSERT INTO items VALUES ('a' || 'b', 'a', NULL);",
+			err:   ErrInvalidDatatype,
+		},
+		{
+			stmts: "INSERT INTO items VALUES (1, 'a');",
+			err:   ErrMissingValues,
+		},
+		{
+			stmts: "INSERT INTO items VALUES (1, 'a', NULL); UPDATE items SET t = i, b = 't';",
+			query: "SELECT * FROM items;",
+			rows:  [][]string{{"1", "1", "true"}},
+		},
+		{
+			stmts: "INSERT INTO items VALUES (1, 'a', NULL); UPDATE items SET i = 'y';",
+			err:   ErrInvalidDatatype,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+
+	_, err := run(NewMemoryBackend(), setup+"INSERT INTO items VALUES ('x', 'a', NULL);")
+	var mismatch *DatatypeMismatchError
+	if assert.ErrorAs(t, err, &mismatch) {
+		assert.Equal(t, DatatypeMismatchError{Column: "i", Expected: IntType, Actual: TextType}, *mismatch)
+	}
+}
+
+func TestIntegerTypes(t *testing.T) {
+	setup := `CREATE TABLE ints (s SMALLINT, i INTEGER, b BIGINT);
+	INSERT INTO ints VALUES (32767, 2147483647, 9223372036854775807);
+	INSERT INTO ints VALUES (-32768, -2147483648, -9223372036854775808);`
+
+	tests := []statementTest{
+		{
+			query: "SELECT s, i, b FROM ints;",
+			rows: [][]string{
+				{"32767", "2147483647", "9223372036854775807"},
+				{"-32768", "-2147483648", "-9223372036854775808"},
+			},
+		},
+		{
+			query: "SELECT s + 1, i - s, b - i FROM ints WHERE s < 0;",
+			rows:  [][]string{{"-32767", "-2147450880", "-9223372034707292160"}},
+		},
+		{
+			query: "SELECT 3000000000 * 2, b / 2 FROM ints WHERE b > 0;",
+			rows:  [][]string{{"6000000000", "4611686018427387903"}},
+		},
+		{
+			stmts: "INSERT INTO ints VALUES (32768, 0, 0);",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			stmts: "INSERT INTO ints VALUES (0, 2147483648, 0);",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			stmts: "INSERT INTO ints VALUES (0, 0, 9223372036854775808);",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			stmts: "SELECT i + 1 FROM ints;",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			stmts: "SELECT b * 2 FROM ints;",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			stmts: "SELECT -b FROM ints WHERE b < 0;",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			stmts: "SELECT b / -1 FROM ints WHERE b < 0;",
+			err:   ErrIntegerOutOfRange,
+		},
+		{
+			stmts: "UPDATE ints SET s = i;",
+			err:   ErrIntegerOutOfRange,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+
+	results, err := run(NewMemoryBackend(), setup+"SELECT b FROM ints;")
+	if assert.Nil(t, err) {
+		assert.Equal(t, int64(math.MaxInt64), results.Rows[0][0].AsInt64())
+		assert.Equal(t, int64(math.MinInt64), results.Rows[1][0].AsInt64())
+	}
+}
+
+func TestNumericTypes(t *testing.T) {
+	setup := `CREATE TABLE nums (r REAL, d DOUBLE PRECISION, n NUMERIC(5,2), m NUMERIC, dd DECIMAL(3));
+	INSERT INTO nums VALUES (1.5, 2.25, 1.005, 1.23456789, 2.5);
+	INSERT INTO nums VALUES (1e3, 1e-3, -1.005, 10, 1.4);`
+
+	tests := []statementTest{
+		{
+			query: "SELECT * FROM nums;",
+			rows:  [][]string{{"1.5", "2.25", "1.01", "1.23456789", "3"}, {"1000", "0.001", "-1.01", "10", "1"}},
+		},
+		{
+			query: "SELECT r + d, n + m, r * n, d / 0.5 FROM nums;",
+			rows:  [][]string{{"3.75", "2.24456789", "1.515", "4.5"}, {"1000.001", "8.99", "-1010", "0.002"}},
+		},
+		{
+			stmts: "INSERT INTO nums VALUES ('1.5', '2', '3.14159', '4', '5');",
+			query: "SELECT n FROM nums WHERE n > 3;",
+			rows:  [][]string{{"3.14"}},
+		},
+		{
+			query: "SELECT 7 / 2.0, 1.5 > 1, 2 >= 2.0 FROM nums WHERE r > 1000 - 1;",
+			rows:  [][]string{{"3.50000000000000000000", "true", "true"}},
+		},
+		{
+			stmts: "SELECT 1.0 / (r - r) FROM nums;",
+			err:   ErrDivisionByZero,
+		},
+		{
+			stmts: "INSERT INTO nums VALUES (1, 1, 1000, 1, 1);",
+			err:   ErrNumericFieldOverflow,
+		},
+		{
+			stmts: "INSERT INTO nums VALUES (1, 1, 1, 1, 999.5);",
+			err:   ErrNumericFieldOverflow,
+		},
+		{
+			stmts: "CREATE TABLE bad (n NUMERIC(2,3));",
+			err:   ErrInvalidTypeModifier,
+		},
+		{
+			stmts: "CREATE TABLE bad (n NUMERIC(0));",
+			err:   ErrInvalidTypeModifier,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestTemporalTypes(t *testing.T) {
+	setup := `CREATE TABLE events (d DATE, t TIME, ts TIMESTAMP, iv INTERVAL);
+	INSERT INTO events VALUES ('2024-01-02', '10:00', '2024-01-02 10:00:00.5', '1 day');
+	INSERT INTO events VALUES ('2023-12-31', '09:15:30', '2023-12-31 23:59', '-2 hours');`
+
+	tests := []statementTest{
+		{
+			query: "SELECT * FROM events ORDER BY d;",
+			rows: [][]string{
+				{"2023-12-31", "09:15:30", "2023-12-31 23:59:00", "-02:00:00"},
+				{"2024-01-02", "10:00:00", "2024-01-02 10:00:00.5", "1 day"},
+			},
+		},
+		{
+			query: "SELECT d FROM events WHERE ts > DATE '2024-01-01' AND iv > INTERVAL '23 hours';",
+			rows:  [][]string{{"2024-01-02"}},
+		},
+		{
+			query: "SELECT DATE '2024-02-28' + 2, DATE '2024-03-01' - DATE '2024-02-01', TIME '23:30' + INTERVAL '1 hour';",
+			rows:  [][]string{{"2024-03-01", "29", "00:30:00"}},
+		},
+		{
+			query: "SELECT TIMESTAMP '2024-01-31 10:00' + INTERVAL '1 month', TIMESTAMP '2024-01-02' - TIMESTAMP '2024-01-01 12:00';",
+			rows:  [][]string{{"2024-02-29 10:00:00", "12:00:00"}},
+		},
+		{
+			query: "SELECT INTERVAL '1 day 2 hours' * 2, INTERVAL '24 hours' = INTERVAL '1 day';",
+			rows:  [][]string{{"2 days 04:00:00", "true"}},
+		},
+		{
+			query: "SELECT TIMESTAMP WITH TIME ZONE '2024-01-01 10:00+02';",
+			rows:  [][]string{{"2024-01-01 08:00:00+00"}},
+		},
+		{
+			stmts: "UPDATE events SET d = d + 1, iv = -iv WHERE t < TIME '10:00';",
+			query: "SELECT d, iv FROM events WHERE t < TIME '10:00';",
+			rows:  [][]string{{"2024-01-01", "02:00:00"}},
+		},
+		{
+			stmts: "SELECT DATE '2024-02-30';",
+			err:   ErrInvalidDatetimeFormat,
+		},
+		{
+			stmts: "SELECT TIME '25:00';",
+			err:   ErrInvalidDatetimeFormat,
+		},
+		{
+			stmts: "SELECT INTERVAL '1 fortnight';",
+			err:   ErrInvalidDatetimeUnit,
+		},
+		{
+			stmts: "SELECT DATE '2024-01-01' + DATE '2024-01-01';",
+			err:   ErrInvalidOperands,
+		},
+		{
+			stmts: "INSERT INTO events VALUES ('2024-13-01', NULL, NULL, NULL);",
+			err:   ErrInvalidDatetimeFormat,
+		},
+	}
+
+	runStatementTests(t, setup, tests)
+}
+
+func TestColumnarTables(t *testing.T) {
+	stmts := []string{
+		"INSERT INTO users VALUES (1, 'ann', 10, 1.5);",
+		"INSERT INTO users VALUES (2, 'bob', NULL, 2);",
+		"INSERT INTO users VALUES (3, 'cat', 20, NULL);",
+		"INSERT INTO users VALUES (4, 'dan', 10, 0.25);",
+		"INSERT INTO users VALUES (4, 'eve', 30, 1);",
+		"CREATE INDEX users_team ON users (team);",
+		"SELECT * FROM users WHERE team = 10;",
+		"SELECT name, score * 2 FROM users WHERE team > 5 AND score IS NOT NULL ORDER BY score DESC;",
+		"SELECT id FROM users WHERE NOT (team = 20) OR name >= 'c' LIMIT 2 OFFSET 1;",
+		"UPDATE users SET team = team + 5, name = name || '!' WHERE id >= 3;",
+		"UPDATE users SET id = 3 WHERE id = 1;",
+		"SELECT * FROM users WHERE team = 15;",
+		"DELETE FROM users WHERE team = 10 OR score IS NULL;",
+		"INSERT INTO users VALUES (5, 'fay', 10, 3);",
+		"SELECT * FROM users;",
+		"SELECT * FROM users WHERE team <= 10;",
+	}
+
+	results := map[string][]string{}
+	for _, layout := range []string{"", " USING columnar"} {
+		mb := NewMemoryBackend()
+		_, err := run(mb, "CREATE TABLE users (id INT PRIMARY KEY, name TEXT, team INT, score REAL)"+layout+";")
+		assert.Nil(t, err)
+		assert.Equal(t, layout != "", mb.tables["users"].columnar)
+
+		for _, stmt := range stmts {
+			result := ""
+			if rows, err := run(mb, stmt); err != nil {
+				result = err.Error()
+			} else if rows != nil {
+				result = fmt.Sprint(resultsText(rows))
+			}
+
+			results[stmt] = append(results[stmt], result)
+		}
+	}
+
+	for _, stmt := range stmts {
+		assert.Equal(t, results[stmt][0], results[stmt][1], stmt)
+	}
+
+	assert.Equal(t, "[[2 bob  2] [4 dan! 15 0.25] [5 fay 10 3]]", results["SELECT * FROM users;"][0])
+}
+
+// query runs the setup statements on a new backend and returns the rows
+// of the final query
+func query(t *testing.T, setup []string, q string) (*Rows, error) {
+	conn := &Conn{NewMemoryBackend()}
+	for _, stmt := range setup {
+		_, err := conn.Exec(stmt, nil)
+		assert.Nil(t, err, stmt)
+	}
+
+	rows, err := conn.Query(q, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return rows.(*Rows), nil
+}
+
+// rowsText renders the cells of rows as text, NULLs as empty strings
+func rowsText(rows *Rows) [][]string {
+	values := [][]string{}
+	for _, row := range rows.rows {
+		value := []string{}
+		for _, cell := range row {
+			value = append(value, cell.AsText())
+		}
+		values = append(values, value)
+	}
+
+	return values
+}
+
+func TestSelectColumns(t *testing.T) {
+	setup := []string{
+		"CREATE TABLE users (id INT, name TEXT, born DATE);",
+		"INSERT INTO users VALUES (1, 'Ada', '1815-12-10');",
+	}
+
+	tests := []struct {
+		query   string
+		columns []string
+		types   []string
+		rows    int
+	}{
+		{
+			query:   "SELECT * FROM users;",
+			columns: []string{"id", "name", "born"},
+			types:   []string{"INTEGER", "TEXT", "DATE"},
+			rows:    1,
+		},
+		// Columns are known even when no row matches
+		{
+			query:   "SELECT * FROM users WHERE id > 1;",
+			columns: []string{"id", "name", "born"},
+			types:   []string{"INTEGER", "TEXT", "DATE"},
+			rows:    0,
+		},
+		{
+			query:   "SELECT id + 1.5, name AS who FROM users WHERE false;",
+			columns: []string{"?column?", "who"},
+			types:   []string{"NUMERIC", "TEXT"},
+			rows:    0,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := query(t, setup, test.query)
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.columns, rows.Columns(), test.query)
+		for i, typ := range test.types {
+			assert.Equal(t, typ, rows.ColumnTypeDatabaseTypeName(i), test.query)
+		}
+		assert.Equal(t, test.rows, len(rows.rows), test.query)
+	}
+}
+
+func TestSelectComparisons(t *testing.T) {
+	setup := []string{
+		"CREATE TABLE users (id INT, name TEXT, score REAL, admin BOOLEAN);",
+		"INSERT INTO users VALUES (1, 'ann', 1.5, true);",
+		"INSERT INTO users VALUES (2, 'bob', 2, false);",
+		"INSERT INTO users VALUES (3, 'cat', NULL, NULL);",
+	}
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT name FROM users WHERE id >= 2 AND name < 'cat';",
+			rows:  [][]string{{"bob"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE id <= 1 OR score > 1.5;",
+			rows:  [][]string{{"ann"}, {"bob"}},
+		},
+		{
+			query: "SELECT id = 2, id <> 2, score = 2, admin = false FROM users;",
+			rows:  [][]string{{"false", "true", "false", "false"}, {"true", "false", "true", "true"}, {"false", "true", "", ""}},
+		},
+		{
+			query: "SELECT 1 < 1.5, 2 >= 2.0, 1.5 > 1, 3000000000 > 2, 'b' > 'abc', true > false;",
+			rows:  [][]string{{"true", "true", "true", "true", "true", "true"}},
+		},
+		{
+			query: "SELECT DATE '2024-01-02' > TIMESTAMP '2024-01-01 23:59', TIME '10:00' <= TIME '09:00';",
+			rows:  [][]string{{"true", "false"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE score >= 1.5 AND score < 2;",
+			rows:  [][]string{{"ann"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE name > 1;",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT DATE '2024-01-01' < 1;",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT name FROM users WHERE name = 1;",
+			err:   ErrInvalidOperands,
+		},
+		{
+			query: "SELECT name FROM users WHERE admin <> 1;",
+			err:   ErrInvalidOperands,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := query(t, setup, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if err == nil {
+			assert.Equal(t, test.rows, rowsText(rows), test.query)
+		}
+	}
+}
+
+func TestConditionTypes(t *testing.T) {
+	setup := []string{
+		"CREATE TABLE users (id INT, name TEXT, admin BOOLEAN);",
+		"INSERT INTO users VALUES (1, 'ann', true);",
+		"INSERT INTO users VALUES (2, 'bob', NULL);",
+	}
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT name FROM users WHERE admin;",
+			rows:  [][]string{{"ann"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE NULL;",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT name FROM users WHERE id;",
+			err:   ErrConditionNotBoolean,
+		},
+		{
+			query: "SELECT name FROM users WHERE 'yes';",
+			err:   ErrConditionNotBoolean,
+		},
+		{
+			query: "SELECT count(*) FROM users GROUP BY admin HAVING count(*);",
+			err:   ErrConditionNotBoolean,
+		},
+		{
+			query: "SELECT a.name FROM users a JOIN users b ON a.id;",
+			err:   ErrConditionNotBoolean,
+		},
+		{
+			query: "DELETE FROM users WHERE 1;",
+			err:   ErrConditionNotBoolean,
+		},
+		{
+			query: "UPDATE users SET name = 'x' WHERE name;",
+			err:   ErrConditionNotBoolean,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := query(t, setup, test.query)
+		assert.ErrorIs(t, err, test.err, test.query)
+		if err == nil {
+			assert.Equal(t, test.rows, rowsText(rows), test.query)
+		}
+	}
+}
+
+func TestSelectAggregates(t *testing.T) {
+	setup := []string{
+		"CREATE TABLE sales (id INT, dept TEXT, amount NUMERIC(10,2));",
+		"INSERT INTO sales VALUES (1, 'toys', 10.50);",
+		"INSERT INTO sales VALUES (2, 'toys', 20.25);",
+		"INSERT INTO sales VALUES (3, 'books', NULL);",
+		"INSERT INTO sales VALUES (4, NULL, 5);",
+		"INSERT INTO sales VALUES (5, 'books', 5);",
+	}
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT count(*), count(amount), count(DISTINCT amount), sum(amount), min(dept), max(id) FROM sales;",
+			rows:  [][]string{{"5", "4", "3", "40.75", "books", "5"}},
+		},
+		{
+			query: "SELECT dept, count(*) AS n, sum(amount) FROM sales GROUP BY dept ORDER BY dept;",
+			rows:  [][]string{{"books", "2", "5.00"}, {"toys", "2", "30.75"}, {"", "1", "5.00"}},
+		},
+		{
+			query: "SELECT dept FROM sales GROUP BY dept HAVING sum(amount) > 10;",
+			rows:  [][]string{{"toys"}},
+		},
+		{
+			query: "SELECT dept, max(amount) FROM sales GROUP BY 1 ORDER BY dept;",
+			rows:  [][]string{{"books", "5.00"}, {"toys", "20.25"}, {"", "5.00"}},
+		},
+		{
+			query: "SELECT id FROM sales WHERE dept = (SELECT dept FROM sales GROUP BY 1 ORDER BY dept LIMIT 1);",
+			rows:  [][]string{{"3"}, {"5"}},
+		},
+		{
+			query: "SELECT id % 2 AS odd, avg(id) FROM sales GROUP BY odd ORDER BY odd;",
+			rows:  [][]string{{"0", "3.00000000000000000000"}, {"1", "3.00000000000000000000"}},
+		},
+		// Without GROUP BY there is a single group even with no rows
+		{
+			query: "SELECT count(*), sum(amount) FROM sales WHERE id > 10;",
+			rows:  [][]string{{"0", ""}},
+		},
+		{
+			query: "SELECT dept, count(*) FROM sales WHERE id > 10 GROUP BY dept;",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT id, count(*) FROM sales;",
+			err:   ErrColumnNotGrouped,
+		},
+		{
+			query: "SELECT id FROM sales WHERE count(*) > 1;",
+			err:   ErrMisplacedAggregate,
+		},
+		{
+			query: "SELECT sum(dept) FROM sales;",
+			err:   ErrFunctionDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := query(t, setup, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if err == nil {
+			assert.Equal(t, test.rows, rowsText(rows), test.query)
+		}
+	}
+}
+
+func TestSelectFunctions(t *testing.T) {
+	setup := []string{
+		"CREATE TABLE products (id INT, name TEXT, price NUMERIC(8,2), weight DOUBLE PRECISION);",
+		"INSERT INTO products VALUES (1, '  Desk ', -12.345, 2.5);",
+		"INSERT INTO products VALUES (2, 'héllo', 7.5, NULL);",
+	}
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT lower(name), upper(name), length(name), trim(name), substr(name, 2, 3) FROM products WHERE id = 2;",
+			rows:  [][]string{{"héllo", "HÉLLO", "5", "héllo", "éll"}},
+		},
+		{
+			query: "SELECT substr('hello', -1, 3), trim('xxaxx', 'x');",
+			rows:  [][]string{{"h", "a"}},
+		},
+		{
+			query: "SELECT abs(price), round(price), round(price, 1), round(weight), round(1234.5, -2) FROM products WHERE id = 1;",
+			rows:  [][]string{{"12.35", "-12", "-12.4", "2", "1200"}},
+		},
+		{
+			query: "SELECT coalesce(weight, price), nullif(id, 2), greatest(id, 1.5, NULL), least(price, id) FROM products;",
+			rows:  [][]string{{"2.5", "1", "1.5", "-12.35"}, {"7.5", "", "2", "2"}},
+		},
+		{
+			query: "SELECT lower(NULL), coalesce(NULL, 'none');",
+			rows:  [][]string{{"", "none"}},
+		},
+		// coalesce only evaluates arguments until one isn't NULL
+		{
+			query: "SELECT coalesce(weight, price / (id - id)) FROM products WHERE id = 1;",
+			rows:  [][]string{{"2.5"}},
+		},
+		{
+			query: "SELECT coalesce(weight, price / (id - id)) FROM products;",
+			err:   ErrDivisionByZero,
+		},
+		{
+			query: "SELECT lower(id) FROM products;",
+			err:   ErrFunctionDoesNotExist,
+		},
+		{
+			query: "SELECT substr(name) FROM products;",
+			err:   ErrFunctionDoesNotExist,
+		},
+		{
+			query: "SELECT substr(name, 1, -1) FROM products;",
+			err:   ErrNegativeSubstringLength,
+		},
+		{
+			query: "SELECT coalesce(id, name) FROM products;",
+			err:   ErrFunctionDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := query(t, setup, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if err == nil {
+			assert.Equal(t, test.rows, rowsText(rows), test.query)
+		}
+	}
+}
+
+// concat joins the text of a group
+type concat struct {
+	parts []string
+}
+
+func (c *concat) Add(value Cell) error {
+	c.parts = append(c.parts, value.AsText())
+	return nil
+}
+
+func (c *concat) Result() (interface{}, error) {
+	if c.parts == nil {
+		return nil, nil
+	}
+
+	return strings.Join(c.parts, ","), nil
+}
+
+func TestStatementTime(t *testing.T) {
+	setup := []string{"CREATE TABLE events (id INT);"}
+	for i := 0; i < 500; i++ {
+		setup = append(setup, fmt.Sprintf("INSERT INTO events (id) VALUES (%d);", i))
+	}
+
+	// Every call to now() in a statement returns the time it started,
+	// even in a subquery run again for each row
+	rows, err := query(t, setup, "SELECT count(DISTINCT e.t), min(e.t) = now() FROM (SELECT (SELECT now() FROM events f WHERE f.id = g.id) AS t FROM events g) AS e;")
+	if assert.Nil(t, err) {
+		assert.Equal(t, [][]string{{"1", "true"}}, rowsText(rows))
+	}
+}
+
+func TestRegisterFunctions(t *testing.T) {
+	mb := NewMemoryBackend()
+	err := mb.RegisterFunction("double", []ColumnType{BigIntType}, BigIntType, func(args []Cell) (interface{}, error) {
+		return args[0].AsInt64() * 2, nil
+	})
+	assert.Nil(t, err)
+
+	err = mb.RegisterFunction("half", []ColumnType{NumericType}, NumericType, func(args []Cell) (interface{}, error) {
+		return new(big.Rat).Quo(args[0].AsNumeric(), big.NewRat(2, 1)), nil
+	})
+	assert.Nil(t, err)
+
+	err = mb.RegisterAggregate("CONCAT_ALL", TextType, TextType, func() Aggregator { return &concat{} })
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrFunctionAlreadyExists, mb.RegisterFunction("Double", nil, IntType, nil))
+	assert.Equal(t, ErrFunctionAlreadyExists, mb.RegisterAggregate("count", IntType, IntType, nil))
+
+	db := sql.OpenDB(NewConnector(mb))
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE users (name TEXT, age INT, team INT);",
+		"INSERT INTO users VALUES ('Terry', 45, 1);",
+		"INSERT INTO users VALUES ('Anette', 57, 1);",
+		"INSERT INTO users VALUES ('Kim', NULL, 2);",
+	} {
+		_, err := db.Exec(stmt)
+		assert.Nil(t, err, stmt)
+	}
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   string
+	}{
+		{
+			query: "SELECT name, double(age), half(age) FROM users WHERE double(age) > 100;",
+			rows:  [][]string{{"Anette", "114", "28.5"}},
+		},
+		{
+			query: "SELECT double(age) FROM users WHERE age IS NULL;",
+			rows:  [][]string{{""}},
+		},
+		{
+			query: "SELECT team, concat_all(name), concat_all(DISTINCT 'x') FROM users WHERE age IS NOT NULL GROUP BY team;",
+			rows:  [][]string{{"1", "Terry,Anette", "x"}},
+		},
+		{
+			query: "SELECT double(name) FROM users;",
+			err:   ErrFunctionDoesNotExist.Error(),
+		},
+		{
+			query: "SELECT concat_all(age) FROM users;",
+			err:   ErrFunctionDoesNotExist.Error(),
+		},
+		{
+			query: "SELECT double(concat_all(name)) FROM users;",
+			err:   ErrFunctionDoesNotExist.Error(),
+		},
+		{
+			query: "SELECT team FROM users WHERE concat_all(name) = '';",
+			err:   ErrMisplacedAggregate.Error(),
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := db.Query(test.query)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, test.query)
+			continue
+		}
+
+		if !assert.Nil(t, err, test.query) {
+			continue
+		}
+
+		result := [][]string{}
+		for rows.Next() {
+			var a, b, c sql.NullString
+			dest := []interface{}{&a, &b, &c}
+			columns, _ := rows.Columns()
+			assert.Nil(t, rows.Scan(dest[:len(columns)]...), test.query)
+
+			row := []string{}
+			for _, value := range dest[:len(columns)] {
+				row = append(row, value.(*sql.NullString).String)
+			}
+			result = append(result, row)
+		}
+
+		assert.Equal(t, test.rows, result, test.query)
+	}
+}
+
+func TestSelectJoins(t *testing.T) {
+	setup := []string{
+		"CREATE TABLE users (id INT, name TEXT, team INT);",
+		"INSERT INTO users VALUES (1, 'ann', 10);",
+		"INSERT INTO users VALUES (2, 'bob', 20);",
+		"INSERT INTO users VALUES (3, 'cat', NULL);",
+		"CREATE TABLE orders (id INT, user_id BIGINT, total NUMERIC(8,2));",
+		"INSERT INTO orders VALUES (100, 1, 5.5);",
+		"INSERT INTO orders VALUES (101, 1, 7);",
+		"INSERT INTO orders VALUES (102, 9, 1);",
+		"CREATE TABLE teams (team INT, title TEXT);",
+		"INSERT INTO teams VALUES (10, 'red');",
+		"INSERT INTO teams VALUES (30, 'blue');",
+	}
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT u.name, o.id FROM users u JOIN orders o ON o.user_id = u.id;",
+			rows:  [][]string{{"ann", "100"}, {"ann", "101"}},
+		},
+		{
+			query: "SELECT u.name, o.id FROM users AS u LEFT JOIN orders o ON o.user_id = u.id AND o.total > 6;",
+			rows:  [][]string{{"ann", "101"}, {"bob", ""}, {"cat", ""}},
+		},
+		{
+			query: "SELECT u.name, o.id FROM users u RIGHT JOIN orders o ON o.user_id = u.id;",
+			rows:  [][]string{{"ann", "100"}, {"ann", "101"}, {"", "102"}},
+		},
+		{
+			query: "SELECT team, users.team, title FROM users FULL OUTER JOIN teams USING (team) ORDER BY team;",
+			rows:  [][]string{{"10", "10", "red"}, {"20", "20", ""}, {"30", "", "blue"}, {"", "", ""}},
+		},
+		{
+			query: "SELECT * FROM users JOIN teams USING (team);",
+			rows:  [][]string{{"10", "1", "ann", "red"}},
+		},
+		{
+			query: "SELECT a.id, b.id FROM users a, users b WHERE a.id < b.id;",
+			rows:  [][]string{{"1", "2"}, {"1", "3"}, {"2", "3"}},
+		},
+		{
+			query: "SELECT name, count(o.id) FROM users u LEFT JOIN orders o ON o.user_id = u.id GROUP BY u.name ORDER BY name;",
+			rows:  [][]string{{"ann", "2"}, {"bob", "0"}, {"cat", "0"}},
+		},
+		{
+			query: "SELECT id FROM users JOIN orders ON users.id = orders.user_id;",
+			err:   ErrAmbiguousColumn,
+		},
+		{
+			query: "SELECT users.name FROM users u;",
+			err:   ErrColumnDoesNotExist,
+		},
+		{
+			query: "SELECT * FROM users JOIN users ON true;",
+			err:   ErrDuplicateTableName,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := query(t, setup, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if err == nil {
+			assert.Equal(t, test.rows, rowsText(rows), test.query)
+		}
+	}
+}
+
+func TestQualifiedDML(t *testing.T) {
+	setup := []string{
+		"CREATE TABLE users (id INT PRIMARY KEY, name TEXT);",
+		"INSERT INTO users VALUES (1, 'ann');",
+		"INSERT INTO users VALUES (2, 'bob');",
+	}
+
+	tests := []struct {
+		stmt string
+		rows [][]string
+		err  error
+	}{
+		{
+			stmt: "UPDATE users SET name = users.name || '!' WHERE users.id = 1;",
+			rows: [][]string{{"1", "ann!"}, {"2", "bob"}},
+		},
+		{
+			stmt: "DELETE FROM users WHERE users.name = 'bob';",
+			rows: [][]string{{"1", "ann"}},
+		},
+		{
+			stmt: "DELETE FROM users WHERE orders.id = 1;",
+			err:  ErrColumnDoesNotExist,
+		},
+	}
+
+	for _, test := range tests {
+		conn := &Conn{NewMemoryBackend()}
+		for _, stmt := range setup {
+			_, err := conn.Exec(stmt, nil)
+			assert.Nil(t, err, stmt)
+		}
+
+		_, err := conn.Exec(test.stmt, nil)
+		assert.ErrorIs(t, err, test.err, test.stmt)
+		if err != nil {
+			continue
+		}
+
+		rows, err := conn.Query("SELECT id, name FROM users;", nil)
+		if assert.Nil(t, err, test.stmt) {
+			assert.Equal(t, test.rows, rowsText(rows.(*Rows)), test.stmt)
+		}
+	}
+}
+
+func TestSelectSubqueries(t *testing.T) {
+	setup := []string{
+		"CREATE TABLE users (id INT, name TEXT, team INT);",
+		"INSERT INTO users VALUES (1, 'ann', 10);",
+		"INSERT INTO users VALUES (2, 'bob', 20);",
+		"INSERT INTO users VALUES (3, 'cat', NULL);",
+		"CREATE TABLE orders (id INT, user_id BIGINT, total NUMERIC(8,2));",
+		"INSERT INTO orders VALUES (100, 1, 5.5);",
+		"INSERT INTO orders VALUES (101, 1, 7);",
+		"INSERT INTO orders VALUES (102, 2, 1);",
+	}
+
+	tests := []struct {
+		query string
+		rows  [][]string
+		err   error
+	}{
+		{
+			query: "SELECT name FROM users WHERE id = (SELECT min(user_id) FROM orders);",
+			rows:  [][]string{{"ann"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE id NOT IN (SELECT user_id FROM orders);",
+			rows:  [][]string{{"cat"}},
+		},
+		{
+			query: "SELECT name, id IN (SELECT team FROM users) FROM users;",
+			rows:  [][]string{{"ann", ""}, {"bob", ""}, {"cat", ""}},
+		},
+		{
+			query: "SELECT name FROM users u WHERE EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.id);",
+			rows:  [][]string{{"ann"}, {"bob"}},
+		},
+		{
+			query: "SELECT name, (SELECT count(*) FROM orders o WHERE o.user_id = u.id) FROM users u;",
+			rows:  [][]string{{"ann", "2"}, {"bob", "1"}, {"cat", "0"}},
+		},
+		{
+			query: "SELECT t.n, t.c FROM (SELECT user_id AS n, count(*) AS c FROM orders GROUP BY user_id) AS t ORDER BY t.n;",
+			rows:  [][]string{{"1", "2"}, {"2", "1"}},
+		},
+		{
+			query: "SELECT u.id, count(*) FROM users u GROUP BY u.id HAVING EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.id);",
+			rows:  [][]string{{"1", "1"}, {"2", "1"}},
+		},
+		{
+			query: "SELECT u.id, (SELECT count(*) FROM orders o WHERE o.user_id = u.id) FROM users u GROUP BY u.id ORDER BY u.id;",
+			rows:  [][]string{{"1", "2"}, {"2", "1"}, {"3", "0"}},
+		},
+		{
+			query: "SELECT u.id FROM users u GROUP BY u.id HAVING EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.team);",
+			err:   ErrColumnNotGrouped,
+		},
+		{
+			query: "SELECT name FROM users u WHERE id IN (SELECT user_id FROM orders LIMIT u.id);",
+			rows:  [][]string{{"ann"}},
+		},
+		{
+			query: "SELECT name, (SELECT count(*) FROM (SELECT 1 FROM orders OFFSET u.id) AS o) FROM users u;",
+			rows:  [][]string{{"ann", "2"}, {"bob", "1"}, {"cat", "0"}},
+		},
+		// Subqueries only run once a row needs their value
+		{
+			query: "SELECT name, (SELECT 1 / (id - id) FROM orders LIMIT 1) FROM users WHERE false;",
+			rows:  [][]string{},
+		},
+		{
+			query: "SELECT (SELECT id FROM orders);",
+			err:   ErrSubqueryRows,
+		},
+		{
+			query: "SELECT name FROM users WHERE id IN (SELECT id, user_id FROM orders);",
+			err:   ErrSubqueryColumns,
+		},
+		{
+			query: "SELECT name FROM users WHERE id IN (SELECT total FROM orders);",
+			rows:  [][]string{{"ann"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE name IN (SELECT id FROM orders);",
+			err:   ErrInvalidOperands,
+		},
+		// * refers to the columns of a derived table by position, they
+		// may share a name or have none
+		{
+			query: "SELECT * FROM (SELECT u.id, o.id FROM users u JOIN orders o ON o.user_id = u.id WHERE o.id = 102) AS x;",
+			rows:  [][]string{{"2", "102"}},
+		},
+		{
+			query: "SELECT * FROM (SELECT 1, 2) AS x;",
+			rows:  [][]string{{"1", "2"}},
+		},
+		{
+			query: "SELECT * FROM (SELECT 1 AS a, 2 AS a) AS x;",
+			rows:  [][]string{{"1", "2"}},
+		},
+		{
+			query: "SELECT *, count(*) FROM (SELECT id, id FROM users) AS x GROUP BY 1, 2 ORDER BY 1 DESC;",
+			rows:  [][]string{{"3", "3", "1"}, {"2", "2", "1"}, {"1", "1", "1"}},
+		},
+		// An untyped NULL selected by the subquery has the type of exp
+		{
+			query: "SELECT name, name IN (SELECT NULL), name NOT IN (SELECT NULL FROM orders WHERE false) FROM users WHERE id = 1;",
+			rows:  [][]string{{"ann", "", "true"}},
+		},
+		{
+			query: "SELECT name FROM users WHERE NULL IN (SELECT name FROM users);",
+			rows:  [][]string{},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := query(t, setup, test.query)
+		assert.Equal(t, test.err, err, test.query)
+		if err == nil {
+			assert.Equal(t, test.rows, rowsText(rows), test.query)
+		}
+	}
+}
+
+func TestCorrelatedDML(t *testing.T) {
+	setup := []string{
+		"CREATE TABLE users (id INT, name TEXT);",
+		"INSERT INTO users VALUES (1, 'ann');",
+		"INSERT INTO users VALUES (2, 'bob');",
+		"INSERT INTO users VALUES (3, 'cat');",
+		"CREATE TABLE orders (id INT, user_id INT, total NUMERIC(8,2));",
+		"INSERT INTO orders VALUES (100, 1, 5.5);",
+		"INSERT INTO orders VALUES (101, 1, 7);",
+		"INSERT INTO orders VALUES (102, 2, 1);",
+	}
+
+	tests := []struct {
+		stmt  string
+		query string
+		rows  [][]string
+	}{
+		{
+			stmt:  "DELETE FROM users WHERE NOT EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id);",
+			query: "SELECT name FROM users;",
+			rows:  [][]string{{"ann"}, {"bob"}},
+		},
+		{
+			stmt:  "UPDATE orders SET total = (SELECT users.id * 10 FROM users WHERE users.id = orders.user_id);",
+			query: "SELECT id, total FROM orders;",
+			rows:  [][]string{{"100", "10.00"}, {"101", "10.00"}, {"102", "20.00"}},
+		},
+		{
+			stmt:  "UPDATE users SET name = 'big' WHERE (SELECT sum(total) FROM orders o WHERE o.user_id = users.id) > 10;",
+			query: "SELECT name FROM users;",
+			rows:  [][]string{{"big"}, {"bob"}, {"cat"}},
+		},
+	}
+
+	for _, test := range tests {
+		conn := &Conn{NewMemoryBackend()}
+		for _, stmt := range append(setup, test.stmt) {
+			_, err := conn.Exec(stmt, nil)
+			assert.Nil(t, err, stmt)
+		}
+
+		rows, err := conn.Query(test.query, nil)
+		if assert.Nil(t, err, test.query) {
+			assert.Equal(t, test.rows, rowsText(rows.(*Rows)), test.stmt)
+		}
+	}
+}
+
+func TestDriverParameters(t *testing.T) {
+	db := sql.OpenDB(NewConnector(NewMemoryBackend()))
+	defer db.Close()
+
+	_, err := db.Exec("CREATE TABLE users (id INT);")
+	assert.Nil(t, err)
+
+	_, err = db.Exec("INSERT INTO users VALUES ($1);", 1)
+	assert.ErrorIs(t, err, ErrParametersNotSupported)
+
+	_, err = db.Query("SELECT id FROM users WHERE id = $1;", 1)
+	assert.ErrorIs(t, err, ErrParametersNotSupported)
+
+	_, err = db.Exec("INSERT INTO nope VALUES (1);")
+	assert.ErrorIs(t, err, ErrTableDoesNotExist)
+}
+
+func TestDriverFloats(t *testing.T) {
+	db := sql.OpenDB(NewConnector(NewMemoryBackend()))
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE points (r REAL, d DOUBLE PRECISION);",
+		"INSERT INTO points VALUES (0.1, 0.1);",
+		"INSERT INTO points VALUES (1.7e38, 1e300);",
+	} {
+		_, err := db.Exec(stmt)
+		assert.Nil(t, err, stmt)
+	}
+
+	rows, err := db.Query("SELECT r, d FROM points;")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer rows.Close()
+
+	// Reals scan as the float64 their text reads as
+	values := [][]float64{}
+	for rows.Next() {
+		var r, d float64
+		assert.Nil(t, rows.Scan(&r, &d))
+		values = append(values, []float64{r, d})
+	}
+
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, [][]float64{{0.1, 0.1}, {1.7e38, 1e300}}, values)
+}
+
+func TestIndexDelete(t *testing.T) {
+	idx := newIndex("test", 0, IntType, false)
+	keys := map[uint]MemoryCell{}
+	r := rand.New(rand.NewSource(1))
+
+	// Duplicate keys exercise the ordering on row numbers as well
+	for i := uint(0); i < 5000; i++ {
+		keys[i] = encodeInt(int64(r.Intn(500)), IntType)
+		assert.Nil(t, idx.add(keys[i], i))
+	}
+
+	for _, i := range r.Perm(5000)[:4000] {
+		idx.remove(keys[uint(i)], uint(i))
+		delete(keys, uint(i))
+	}
+
+	var previous *indexEntry
+	count := 0
+	idx.tree.ascend(func(indexEntry) bool { return true }, func(e indexEntry) bool {
+		assert.Equal(t, keys[e.rowIndex], e.key)
+		if previous != nil {
+			assert.True(t, idx.tree.less(*previous, e))
+		}
+
+		previous = &e
+		count++
+		return true
+	})
+	assert.Equal(t, len(keys), count)
+
+	for i := range keys {
+		idx.remove(keys[i], i)
+	}
+	assert.Nil(t, idx.tree.root)
+}
+
+func TestUnreservedKeywords(t *testing.T) {
+	setup := []string{
+		"CREATE TABLE people (first TEXT, last TEXT, nulls INT, set INT, default INT DEFAULT 3);",
+		"INSERT INTO people (first, set) VALUES ('ann', 1);",
+		"INSERT INTO people (first, last, nulls) VALUES (NULL, 'lee', 2);",
+		"UPDATE people SET set = 5 WHERE default = 3 AND first IS NOT NULL;",
+		"CREATE TABLE events (date DATE, time TIME, timestamp TIMESTAMP, interval INTERVAL, numeric NUMERIC);",
+		"INSERT INTO events VALUES ('2024-01-02', '10:00', '2024-01-02 10:00', '1 day', 1.5);",
+		"CREATE TABLE sides (left INT, right INT, full TEXT);",
+		"INSERT INTO sides VALUES (1, 2, 'ann');",
+		"CREATE TABLE notes (int INT, text TEXT, boolean BOOLEAN);",
+		"INSERT INTO notes VALUES (1, 'a', true);",
+		"CREATE TABLE ranks (asc INT, desc INT);",
+		"INSERT INTO ranks VALUES (1, 2);",
+		"INSERT INTO ranks VALUES (2, 1);",
+	}
+
+	tests := []struct {
+		query string
+		rows  [][]string
+	}{
+		{
+			query: "SELECT first, last, nulls, set, default FROM people ORDER BY first NULLS FIRST;",
+			rows:  [][]string{{"", "lee", "2", "", "3"}, {"ann", "", "", "5", "3"}},
+		},
+		{
+			query: "SELECT people.first AS last FROM people ORDER BY last DESC NULLS LAST;",
+			rows:  [][]string{{"ann"}, {""}},
+		},
+		{
+			query: "SELECT date, time, interval, numeric FROM events WHERE timestamp > TIMESTAMP '2024-01-01' AND date = DATE '2024-01-02';",
+			rows:  [][]string{{"2024-01-02", "10:00:00", "1 day", "1.5"}},
+		},
+		{
+			query: "SELECT events.date + 1 FROM events;",
+			rows:  [][]string{{"2024-01-03"}},
+		},
+		{
+			query: "SELECT left, right FROM sides WHERE full = 'ann';",
+			rows:  [][]string{{"1", "2"}},
+		},
+		{
+			query: "SELECT s.full FROM sides s LEFT JOIN people p ON s.right = p.nulls;",
+			rows:  [][]string{{"ann"}},
+		},
+		{
+			query: "SELECT outer.left, p.last FROM sides outer INNER JOIN people p ON outer.right = p.nulls CROSS JOIN events;",
+			rows:  [][]string{{"1", "lee"}},
+		},
+		{
+			query: "SELECT left FROM sides LEFT OUTER JOIN events ON left = 1;",
+			rows:  [][]string{{"1"}},
+		},
+		{
+			query: "SELECT text FROM notes WHERE boolean AND int = 1;",
+			rows:  [][]string{{"a"}},
+		},
+		{
+			query: "SELECT asc FROM ranks ORDER BY desc ASC;",
+			rows:  [][]string{{"2"}, {"1"}},
+		},
+		{
+			query: "SELECT desc FROM ranks ORDER BY asc DESC NULLS LAST;",
+			rows:  [][]string{{"1"}, {"2"}},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := query(t, setup, test.query)
+		if assert.Nil(t, err, test.query) {
+			assert.Equal(t, test.rows, rowsText(rows), test.query)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package pck
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Aggregator folds the values of a group into the result of an aggregate
+// registered with RegisterAggregate. A new one is started for every group.
+type Aggregator interface {
+	// Add is called with each non-NULL value of the group, converted to
+	// the argument type of the aggregate
+	Add(value Cell) error
+	// Result returns the value of the aggregate, in any of the forms a
+	// function registered with RegisterFunction may return
+	Result() (interface{}, error)
+}
+
+// functionRegistry holds the functions registered on a backend. They are
+// looked up after the built-in ones, which can't be replaced.
+type functionRegistry struct {
+	scalars    map[string]scalarFunction
+	aggregates map[string]aggregateFunction
+}
+
+func newFunctionRegistry() *functionRegistry {
+	return &functionRegistry{
+		scalars:    map[string]scalarFunction{},
+		aggregates: map[string]aggregateFunction{},
+	}
+}
+
+// scalar looks a scalar function up, a nil registry only has the built-in
+// functions
+func (r *functionRegistry) scalar(name string) (scalarFunction, bool) {
+	if fn, ok := scalarFunctions[name]; ok || r == nil {
+		return fn, ok
+	}
+
+	fn, ok := r.scalars[name]
+	return fn, ok
+}
+
+func (r *functionRegistry) aggregate(name string) (aggregateFunction, bool) {
+	if fn, ok := aggregateFunctions[name]; ok || r == nil {
+		return fn, ok
+	}
+
+	fn, ok := r.aggregates[name]
+	return fn, ok
+}
+
+func (r *functionRegistry) exists(name string) bool {
+	_, scalar := r.scalar(name)
+	_, aggregate := r.aggregate(name)
+	return scalar || aggregate
+}
+
+// scope returns a scope for the expressions of a query on t, outer is
+// nil unless the query is a subquery
+func (mb *MemoryBackend) scope(t *table, outer *outerRow) *scope {
+	return &scope{t: t, functions: mb.functions, mb: mb, outer: outer}
+}
+
+// RegisterFunction makes fn callable from queries as name. Arguments are
+// converted to argTypes, and fn is only called when none of them is NULL,
+// otherwise the call returns NULL. fn returns nil for NULL, or a value of
+// the Go type matching returnType: an integer for the integer types, a
+// float for REAL and DOUBLE PRECISION, a *big.Rat or decimal string for
+// NUMERIC, a string for TEXT, a bool for BOOLEAN, a time.Time for the
+// date and time types or an Interval. A string is also accepted for any
+// type and read like a literal of that type. As with the built-in
+// functions, a call with constant arguments may only be evaluated once
+// per statement.
+func (mb *MemoryBackend) RegisterFunction(name string, argTypes []ColumnType, returnType ColumnType, fn func(args []Cell) (interface{}, error)) error {
+	name = strings.ToLower(name)
+	if mb.functions.exists(name) {
+		return ErrFunctionAlreadyExists
+	}
+
+	mb.functions.scalars[name] = scalarFunction{
+		resolve: fixed(returnType, argTypes...),
+		call: func(args []MemoryCell, _ []ColumnType, typ ColumnType) (MemoryCell, error) {
+			cells := make([]Cell, len(args))
+			for i, arg := range args {
+				cells[i] = arg
+			}
+
+			value, err := fn(cells)
+			if err != nil {
+				return MemoryCell{}, err
+			}
+
+			return goValueToMemoryCell(value, typ)
+		},
+		strict: true,
+	}
+
+	return nil
+}
+
+// RegisterAggregate makes an aggregate callable from queries as name. It
+// takes a single argument, converted to argType, and its result is
+// computed by an Aggregator from start for every group. Like the
+// built-in aggregates it may be called on DISTINCT values.
+func (mb *MemoryBackend) RegisterAggregate(name string, argType, returnType ColumnType, start func() Aggregator) error {
+	name = strings.ToLower(name)
+	if mb.functions.exists(name) {
+		return ErrFunctionAlreadyExists
+	}
+
+	mb.functions.aggregates[name] = aggregateFunction{
+		returnType: func(typ ColumnType) (ColumnType, bool) {
+			return returnType, argument{typ: typ}.accepts(argType)
+		},
+		start: func(typ ColumnType) accumulator {
+			return &userAccumulator{from: typ, to: argType, typ: returnType, aggregator: start()}
+		},
+	}
+
+	return nil
+}
+
+// userAccumulator hands the values of a group to an Aggregator
+type userAccumulator struct {
+	from, to, typ ColumnType
+	aggregator    Aggregator
+}
+
+func (a *userAccumulator) add(value MemoryCell) error {
+	value, err := coerceCell(value, a.from, a.to, false)
+	if err != nil {
+		return err
+	}
+
+	return a.aggregator.Add(value)
+}
+
+func (a *userAccumulator) result() (MemoryCell, error) {
+	value, err := a.aggregator.Result()
+	if err != nil {
+		return MemoryCell{}, err
+	}
+
+	return goValueToMemoryCell(value, a.typ)
+}
+
+// goValueToMemoryCell converts a value returned by a user-defined function
+// to a cell of type typ, see RegisterFunction for the accepted values
+func goValueToMemoryCell(value interface{}, typ ColumnType) (MemoryCell, error) {
+	var cell MemoryCell
+	var from ColumnType
+	untyped := false
+
+	switch v := value.(type) {
+	case nil:
+		return MemoryCell{}, nil
+	case int:
+		cell, from = encodeInt(int64(v), BigIntType), BigIntType
+	case int16:
+		cell, from = encodeInt(int64(v), BigIntType), BigIntType
+	case int32:
+		cell, from = encodeInt(int64(v), BigIntType), BigIntType
+	case int64:
+		cell, from = encodeInt(v, BigIntType), BigIntType
+	case float32:
+		cell, from = encodeFloat(float64(v), RealType), RealType
+	case float64:
+		cell, from = encodeFloat(v, DoubleType), DoubleType
+	case *big.Rat:
+		// Drop the zeros the fixed number of digits leaves behind
+		s := strings.TrimRight(v.FloatString(numericDivScale), "0")
+		cell, from = numericCell(strings.TrimSuffix(s, ".")), NumericType
+	case string:
+		cell, from, untyped = textCell(v), TextType, true
+	case bool:
+		cell, from = boolToMemoryCell(v), BoolType
+	case Interval:
+		cell, from = encodeInterval(v), IntervalType
+	case time.Time:
+		return timeToMemoryCell(v, typ)
+	default:
+		return MemoryCell{}, ErrInvalidFunctionResult
+	}
+
+	cell, err := coerceCell(cell, from, typ, untyped)
+	if errors.Is(err, ErrInvalidDatatype) {
+		return MemoryCell{}, ErrInvalidFunctionResult
+	}
+
+	return cell, err
+}
+
+func timeToMemoryCell(t time.Time, typ ColumnType) (MemoryCell, error) {
+	t = t.UTC()
+	switch typ {
+	case DateType:
+		return encodeDate(t)
+	case TimestampType, TimestampTzType:
+		return encodeTimestamp(t.Truncate(time.Microsecond), typ)
+	case TimeType:
+		midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return encodeTime(t.Sub(midnight).Microseconds()), nil
+	default:
+		return MemoryCell{}, ErrInvalidFunctionResult
+	}
+}
@@ -0,0 +1,90 @@
+package pck
+
+// vectorBatchSize is the number of rows a filter is evaluated over at
+// once
+const vectorBatchSize = 1024
+
+// vector holds the values of an expression over a batch of rows. A
+// constant expression is evaluated once and its single value stands for
+// every row.
+type vector struct {
+	cells    []MemoryCell
+	typ      ColumnType
+	constant bool
+}
+
+func (v vector) at(i int) MemoryCell {
+	if v.constant {
+		return v.cells[0]
+	}
+
+	return v.cells[i]
+}
+
+// promote converts the values of v from one type to the type
+// promotedType chose, leaving v itself untouched since it may share the
+// cells of a column
+func (v vector) promote(from, to ColumnType) (vector, error) {
+	cells := make([]MemoryCell, len(v.cells))
+	for i, cell := range v.cells {
+		var err error
+		if cells[i], err = promoteOperand(cell, from, to); err != nil {
+			return vector{}, err
+		}
+	}
+
+	return vector{cells: cells, typ: to, constant: v.constant}, nil
+}
+
+// filterRows returns the rows of the table for which where is true, in
+// order. When max isn't negative the scan stops once max rows were found,
+// rows past them are not evaluated at all.
+func (sc *scope) filterRows(where *expression, max int) ([]uint, error) {
+	var filter *compiledExpression
+	if where != nil {
+		c, err := sc.compileCondition(*where)
+		if err != nil {
+			return nil, err
+		}
+
+		filter = &c
+	}
+
+	matches := []uint{}
+	count := sc.t.rowCount()
+	for start := uint(0); start < count; {
+		if max >= 0 && len(matches) >= max {
+			break
+		}
+
+		end := start + vectorBatchSize
+		if max >= 0 && end-start > uint(max-len(matches)) {
+			end = start + uint(max-len(matches))
+		}
+
+		if end > count {
+			end = count
+		}
+
+		if filter == nil {
+			for i := start; i < end; i++ {
+				matches = append(matches, i)
+			}
+		} else {
+			v, err := filter.batch(start, end)
+			if err != nil {
+				return nil, err
+			}
+
+			for i := start; i < end; i++ {
+				if v.at(int(i - start)).AsBool() {
+					matches = append(matches, i)
+				}
+			}
+		}
+
+		start = end
+	}
+
+	return matches, nil
+}
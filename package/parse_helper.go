@@ -1,5 +1,7 @@
 package pck
 
+import "strings"
+
 func parseStatement(tokens []*Token, initialCursor uint, delimiter Token) (*Statement, uint, bool) {
 	cursor := initialCursor
 
@@ -22,6 +24,24 @@ func parseStatement(tokens []*Token, initialCursor uint, delimiter Token) (*Stat
 		}, newCursor, true
 	}
 
+	// Look for an UPDATE statement
+	updt, newCursor, ok := parseUpdateStatement(tokens, cursor, semicolonToken)
+	if ok {
+		return &Statement{
+			Kind:            UpdateKind,
+			UpdateStatement: updt,
+		}, newCursor, true
+	}
+
+	// Look for a DELETE statement
+	dlt, newCursor, ok := parseDeleteStatement(tokens, cursor, semicolonToken)
+	if ok {
+		return &Statement{
+			Kind:            DeleteKind,
+			DeleteStatement: dlt,
+		}, newCursor, true
+	}
+
 	// Look for a CREATE statement
 	crtTbl, newCursor, ok := parseCreateTableStatement(tokens, cursor, semicolonToken)
 	if ok {
@@ -31,9 +51,78 @@ func parseStatement(tokens []*Token, initialCursor uint, delimiter Token) (*Stat
 		}, newCursor, true
 	}
 
+	// Look for a CREATE INDEX statement
+	crtIdx, newCursor, ok := parseCreateIndexStatement(tokens, cursor, semicolonToken)
+	if ok {
+		return &Statement{
+			Kind:                 CreateIndexKind,
+			CreateIndexStatement: crtIdx,
+		}, newCursor, true
+	}
+
+	// Look for a DROP TABLE statement
+	drpTbl, newCursor, ok := parseDropTableStatement(tokens, cursor, semicolonToken)
+	if ok {
+		return &Statement{
+			Kind:               DropTableKind,
+			DropTableStatement: drpTbl,
+		}, newCursor, true
+	}
+
+	// Look for a DROP INDEX statement
+	drpIdx, newCursor, ok := parseDropIndexStatement(tokens, cursor, semicolonToken)
+	if ok {
+		return &Statement{
+			Kind:               DropIndexKind,
+			DropIndexStatement: drpIdx,
+		}, newCursor, true
+	}
+
 	return nil, initialCursor, false
 }
 
+// parseIfExists looks for an optional IF EXISTS clause
+func parseIfExists(tokens []*Token, initialCursor uint) (bool, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(IfKeyword)) {
+		return false, initialCursor, true
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(ExistsKeyword)) {
+		helpMessage(tokens, cursor, "Expected EXISTS")
+		return false, initialCursor, false
+	}
+	cursor++
+
+	return true, cursor, true
+}
+
+// parseIfNotExists looks for an optional IF NOT EXISTS clause
+func parseIfNotExists(tokens []*Token, initialCursor uint) (bool, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(IfKeyword)) {
+		return false, initialCursor, true
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(NotKeyword)) {
+		helpMessage(tokens, cursor, "Expected NOT")
+		return false, initialCursor, false
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(ExistsKeyword)) {
+		helpMessage(tokens, cursor, "Expected EXISTS")
+		return false, initialCursor, false
+	}
+	cursor++
+
+	return true, cursor, true
+}
+
 func parseToken(tokens []*Token, initialCursor uint, t Token) (*Token, uint, bool) {
 	cursor := initialCursor
 
@@ -63,10 +152,36 @@ func parseTokenKind(tokens []*Token, initialCursor uint, kind tokenKind) (*Token
 	return nil, initialCursor, false
 }
 
+// parseIdentifier parses a name, which may also be an unreserved keyword
+// since the grammar expects none here
+func parseIdentifier(tokens []*Token, initialCursor uint) (*Token, uint, bool) {
+	if id, newCursor, ok := parseTokenKind(tokens, initialCursor, identifierKind); ok {
+		return id, newCursor, true
+	}
+
+	kw, newCursor, ok := parseTokenKind(tokens, initialCursor, keywordKind)
+	if !ok || !isUnreservedKeyword(keyword(kw.value)) {
+		return nil, initialCursor, false
+	}
+
+	return &Token{
+		value: kw.value,
+		kind:  identifierKind,
+		loc:   kw.loc,
+	}, newCursor, true
+}
+
 func parseLiteralExpression(tokens []*Token, initialCursor uint) (*expression, uint, bool) {
 	cursor := initialCursor
 
-	kinds := []tokenKind{identifierKind, numericKind, stringKind, boolKind, nullKind}
+	if id, newCursor, ok := parseIdentifier(tokens, cursor); ok {
+		return &expression{
+			literal: id,
+			kind:    literalKind,
+		}, newCursor, true
+	}
+
+	kinds := []tokenKind{numericKind, stringKind, boolKind, nullKind}
 	for _, kind := range kinds {
 		t, newCursor, ok := parseTokenKind(tokens, cursor, kind)
 		if ok {
@@ -80,6 +195,133 @@ func parseLiteralExpression(tokens []*Token, initialCursor uint) (*expression, u
 	return nil, initialCursor, false
 }
 
+// parseTypedLiteralExpression parses a string literal preceded by the
+// name of its type, as in TIMESTAMP '2024-01-01 10:00:00'
+func parseTypedLiteralExpression(tokens []*Token, initialCursor uint) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	types := []Token{
+		tokenFromKeyword(DateKeyword),
+		tokenFromKeyword(TimeKeyword),
+		tokenFromKeyword(TimestampKeyword),
+		tokenFromKeyword(TimestamptzKeyword),
+		tokenFromKeyword(IntervalKeyword),
+	}
+
+	for _, typ := range types {
+		t, newCursor, ok := parseToken(tokens, cursor, typ)
+		if !ok {
+			continue
+		}
+
+		value, newCursor, ok := parseTokenKind(tokens, newCursor, stringKind)
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		return &expression{
+			cast: &castExpression{
+				exp: expression{
+					literal: value,
+					kind:    literalKind,
+				},
+				typ: *t,
+			},
+			kind: castKind,
+		}, newCursor, true
+	}
+
+	return nil, initialCursor, false
+}
+
+// parseCallExpression parses a function call. EXTRACT(field FROM source)
+// becomes a call with the field name as its first argument, aggregates
+// also take * or DISTINCT before their argument.
+func parseCallExpression(tokens []*Token, initialCursor uint) (*expression, uint, bool) {
+	cursor := initialCursor
+
+	name, newCursor, ok := parseIdentifier(tokens, cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	rightParenToken := tokenFromSymbol(rightparenSymbol)
+	_, cursor, ok = parseToken(tokens, cursor, tokenFromSymbol(leftparenSymbol))
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	var args *[]*expression
+	var distinct, star bool
+	if name.value == "extract" {
+		if cursor >= uint(len(tokens)) {
+			helpMessage(tokens, cursor, "Expected field to extract")
+			return nil, initialCursor, false
+		}
+
+		field := tokens[cursor]
+		if field.kind != identifierKind && field.kind != keywordKind && field.kind != stringKind {
+			helpMessage(tokens, cursor, "Expected field to extract")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		_, cursor, ok = parseToken(tokens, cursor, tokenFromKeyword(FromKeyword))
+		if !ok {
+			helpMessage(tokens, cursor, "Expected FROM")
+			return nil, initialCursor, false
+		}
+
+		source, newCursor, ok := parseExpression(tokens, cursor, []Token{rightParenToken}, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected expression to extract from")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		args = &[]*expression{
+			{
+				literal: &Token{
+					value: field.value,
+					kind:  stringKind,
+					loc:   field.loc,
+				},
+				kind: literalKind,
+			},
+			source,
+		}
+	} else if _, newCursor, ok := parseToken(tokens, cursor, tokenFromSymbol(asteriskSymbol)); ok {
+		// count(*) takes no argument, it counts rows
+		cursor = newCursor
+		args = &[]*expression{}
+		star = true
+	} else {
+		_, cursor, distinct = parseToken(tokens, cursor, tokenFromKeyword(DistinctKeyword))
+
+		args, cursor, ok = parseExpressions(tokens, cursor, rightParenToken)
+		if !ok {
+			return nil, initialCursor, false
+		}
+	}
+
+	_, cursor, ok = parseToken(tokens, cursor, rightParenToken)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+
+	return &expression{
+		call: &callExpression{
+			name:     *name,
+			args:     args,
+			distinct: distinct,
+			star:     star,
+		},
+		kind: callKind,
+	}, cursor, true
+}
+
 func parseExpressions(tokens []*Token, initialCursor uint, delimiter Token) (*[]*expression, uint, bool) {
 	cursor := initialCursor
 
@@ -116,16 +358,65 @@ func parseExpressions(tokens []*Token, initialCursor uint, delimiter Token) (*[]
 	return &exps, cursor, true
 }
 
+// parseSubquery parses a SELECT in parentheses
+func parseSubquery(tokens []*Token, initialCursor uint) (*SelectStatement, uint, bool) {
+	cursor := initialCursor
+
+	rightParenToken := tokenFromSymbol(rightparenSymbol)
+	if !expectToken(tokens, cursor, tokenFromSymbol(leftparenSymbol)) || !expectToken(tokens, cursor+1, tokenFromKeyword(SelectKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	slct, cursor, ok := parseSelectStatement(tokens, cursor, rightParenToken)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	if !expectToken(tokens, cursor, rightParenToken) {
+		helpMessage(tokens, cursor, "Expected closing paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return slct, cursor, true
+}
+
 func parseExpression(tokens []*Token, initialCursor uint, delimiters []Token, minBp uint) (*expression, uint, bool) {
 	cursor := initialCursor
 
 	var exp *expression
-	_, newCursor, ok := parseToken(tokens, cursor, tokenFromSymbol(leftparenSymbol))
-	if ok {
+	var ok bool
+	if slct, newCursor, ok := parseSubquery(tokens, cursor); ok {
+		exp = &expression{
+			subquery: &subqueryExpression{
+				slct: slct,
+				kind: scalarSubquery,
+			},
+			kind: subqueryKind,
+		}
+		cursor = newCursor
+	} else if _, newCursor, ok := parseToken(tokens, cursor, tokenFromKeyword(ExistsKeyword)); ok {
+		slct, newCursor, ok := parseSubquery(tokens, newCursor)
+		if !ok {
+			helpMessage(tokens, newCursor, "Expected subquery after EXISTS")
+			return nil, initialCursor, false
+		}
+
+		exp = &expression{
+			subquery: &subqueryExpression{
+				slct: slct,
+				kind: existsSubquery,
+			},
+			kind: subqueryKind,
+		}
+		cursor = newCursor
+	} else if _, newCursor, ok := parseToken(tokens, cursor, tokenFromSymbol(leftparenSymbol)); ok {
 		cursor = newCursor
 		rightParenToken := tokenFromSymbol(rightparenSymbol)
 
-		exp, cursor, ok = parseExpression(tokens, cursor, append(delimiters, rightParenToken), minBp)
+		// Parentheses reset precedence
+		exp, cursor, ok = parseExpression(tokens, cursor, append(delimiters, rightParenToken), 0)
 		if !ok {
 			helpMessage(tokens, cursor, "Expected expression after opening paren")
 			return nil, initialCursor, false
@@ -136,6 +427,62 @@ func parseExpression(tokens []*Token, initialCursor uint, delimiters []Token, mi
 			helpMessage(tokens, cursor, "Expected closing paren")
 			return nil, initialCursor, false
 		}
+	} else if op, newCursor, ok := parseToken(tokens, cursor, tokenFromSymbol(MinusSymbol)); ok {
+		cursor = newCursor
+
+		var operand *expression
+		operand, cursor, ok = parseExpression(tokens, cursor, delimiters, unaryBindingPower)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected expression after unary operator")
+			return nil, initialCursor, false
+		}
+
+		if operand.kind == literalKind && operand.literal.kind == numericKind {
+			// Fold negative numbers into the literal so the most negative
+			// integer can be written
+			value := "-" + operand.literal.value
+			if strings.HasPrefix(operand.literal.value, "-") {
+				value = operand.literal.value[1:]
+			}
+
+			exp = &expression{
+				literal: &Token{
+					value: value,
+					kind:  numericKind,
+					loc:   op.loc,
+				},
+				kind: literalKind,
+			}
+		} else {
+			exp = &expression{
+				unary: &unaryExpression{
+					*operand,
+					*op,
+				},
+				kind: unaryKind,
+			}
+		}
+	} else if op, newCursor, ok := parseToken(tokens, cursor, tokenFromKeyword(NotKeyword)); ok {
+		cursor = newCursor
+
+		var operand *expression
+		operand, cursor, ok = parseExpression(tokens, cursor, delimiters, notBindingPower)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected expression after NOT")
+			return nil, initialCursor, false
+		}
+
+		exp = &expression{
+			unary: &unaryExpression{
+				*operand,
+				*op,
+			},
+			kind: unaryKind,
+		}
+	} else if cast, newCursor, ok := parseTypedLiteralExpression(tokens, cursor); ok {
+		exp, cursor = cast, newCursor
+	} else if call, newCursor, ok := parseCallExpression(tokens, cursor); ok {
+		exp, cursor = call, newCursor
 	} else {
 		exp, cursor, ok = parseLiteralExpression(tokens, cursor)
 		if !ok {
@@ -156,10 +503,56 @@ outer:
 		binOps := []Token{
 			tokenFromKeyword(AndKeyword),
 			tokenFromKeyword(OrKeyword),
+			tokenFromKeyword(IsKeyword),
 			tokenFromSymbol(EqSymbol),
 			tokenFromSymbol(NeqSymbol),
+			tokenFromSymbol(LtSymbol),
+			tokenFromSymbol(LteSymbol),
+			tokenFromSymbol(GtSymbol),
+			tokenFromSymbol(GteSymbol),
 			tokenFromSymbol(ConcatSymbol),
 			tokenFromSymbol(PlusSymbol),
+			tokenFromSymbol(MinusSymbol),
+			tokenFromSymbol(asteriskSymbol),
+			tokenFromSymbol(SlashSymbol),
+			tokenFromSymbol(PercentSymbol),
+		}
+
+		// [NOT] IN takes a subquery on the right
+		not, newCursor, negate := parseToken(tokens, cursor, tokenFromKeyword(NotKeyword))
+		if in, newCursor, ok := parseToken(tokens, newCursor, tokenFromKeyword(InKeyword)); ok {
+			if in.bindingPower() < minBp {
+				break
+			}
+
+			slct, newCursor, ok := parseSubquery(tokens, newCursor)
+			if !ok {
+				helpMessage(tokens, newCursor, "Expected subquery after IN")
+				return nil, initialCursor, false
+			}
+
+			exp = &expression{
+				subquery: &subqueryExpression{
+					slct: slct,
+					exp:  exp,
+					kind: inSubquery,
+				},
+				kind: subqueryKind,
+			}
+
+			if negate {
+				exp = &expression{
+					unary: &unaryExpression{
+						*exp,
+						*not,
+					},
+					kind: unaryKind,
+				}
+			}
+
+			cursor = newCursor
+			lastCursor = cursor
+			continue
 		}
 
 		var op *Token = nil
@@ -183,7 +576,46 @@ outer:
 			break
 		}
 
-		b, newCursor, ok := parseExpression(tokens, cursor, delimiters, bp)
+		// IS [NOT] only takes NULL, TRUE or FALSE on the right
+		if op.kind == keywordKind && keyword(op.value) == IsKeyword {
+			not, newCursor, negate := parseToken(tokens, cursor, tokenFromKeyword(NotKeyword))
+			cursor = newCursor
+
+			b, newCursor, ok := parseTokenKind(tokens, cursor, nullKind)
+			if !ok {
+				b, newCursor, ok = parseTokenKind(tokens, cursor, boolKind)
+				if !ok {
+					helpMessage(tokens, cursor, "Expected NULL, TRUE or FALSE after IS")
+					return nil, initialCursor, false
+				}
+			}
+
+			exp = &expression{
+				binary: &binaryExpression{
+					*exp,
+					expression{literal: b, kind: literalKind},
+					*op,
+				},
+				kind: binaryKind,
+			}
+
+			if negate {
+				exp = &expression{
+					unary: &unaryExpression{
+						*exp,
+						*not,
+					},
+					kind: unaryKind,
+				}
+			}
+
+			cursor = newCursor
+			lastCursor = cursor
+			continue
+		}
+
+		// Operators of equal precedence associate to the left
+		b, newCursor, ok := parseExpression(tokens, cursor, delimiters, bp+1)
 		if !ok {
 			helpMessage(tokens, cursor, "Expected right operand")
 			return nil, initialCursor, false
@@ -229,7 +661,7 @@ func parseColumnDefinitions(tokens []*Token, initialCursor uint, delimiter Token
 		}
 
 		// Look for a column name
-		id, newCursor, ok := parseTokenKind(tokens, cursor, identifierKind)
+		id, newCursor, ok := parseIdentifier(tokens, cursor)
 		if !ok {
 			helpMessage(tokens, cursor, "Expected column name")
 			return nil, initialCursor, false
@@ -244,10 +676,89 @@ func parseColumnDefinitions(tokens []*Token, initialCursor uint, delimiter Token
 		}
 		cursor = newCursor
 
-		cds = append(cds, &columnDefinition{
+		cd := columnDefinition{
 			name:     *id,
 			datatype: *ty,
-		})
+		}
+
+		// Look for type modifiers, as in NUMERIC(10, 2)
+		if expectToken(tokens, cursor, tokenFromSymbol(leftparenSymbol)) {
+			cursor++
+
+			cd.precision, cursor, ok = parseTokenKind(tokens, cursor, numericKind)
+			if !ok {
+				helpMessage(tokens, cursor, "Expected precision")
+				return nil, initialCursor, false
+			}
+
+			if expectToken(tokens, cursor, tokenFromSymbol(commaSymbol)) {
+				cursor++
+
+				cd.scale, cursor, ok = parseTokenKind(tokens, cursor, numericKind)
+				if !ok {
+					helpMessage(tokens, cursor, "Expected scale")
+					return nil, initialCursor, false
+				}
+			}
+
+			if !expectToken(tokens, cursor, tokenFromSymbol(rightparenSymbol)) {
+				helpMessage(tokens, cursor, "Expected closing paren")
+				return nil, initialCursor, false
+			}
+
+			cursor++
+		}
+
+		// Look for column constraints
+		primaryKeyToken := tokenFromKeyword(PrimarykeyKeyword)
+		notToken := tokenFromKeyword(NotKeyword)
+		uniqueToken := tokenFromKeyword(UniqueKeyword)
+		defaultToken := tokenFromKeyword(DefaultKeyword)
+		nullToken := Token{kind: nullKind, value: string(NullKeyword)}
+	constraints:
+		for {
+			switch {
+			case expectToken(tokens, cursor, primaryKeyToken):
+				cd.primaryKey = true
+				cursor++
+			case expectToken(tokens, cursor, uniqueToken):
+				cd.unique = true
+				cursor++
+			case expectToken(tokens, cursor, nullToken):
+				cursor++
+			case expectToken(tokens, cursor, notToken):
+				cursor++
+				if !expectToken(tokens, cursor, nullToken) {
+					helpMessage(tokens, cursor, "Expected NULL after NOT")
+					return nil, initialCursor, false
+				}
+
+				cd.notNull = true
+				cursor++
+			case expectToken(tokens, cursor, defaultToken):
+				cursor++
+				delimiters := []Token{
+					tokenFromSymbol(commaSymbol),
+					delimiter,
+					primaryKeyToken,
+					notToken,
+					uniqueToken,
+					nullToken,
+				}
+				exp, newCursor, ok := parseExpression(tokens, cursor, delimiters, 0)
+				if !ok {
+					helpMessage(tokens, cursor, "Expected DEFAULT expression")
+					return nil, initialCursor, false
+				}
+
+				cd.defaultValue = exp
+				cursor = newCursor
+			default:
+				break constraints
+			}
+		}
+
+		cds = append(cds, &cd)
 	}
 
 	return &cds, cursor, true
@@ -297,7 +808,7 @@ outer:
 
 			_, cursor, ok = parseToken(tokens, cursor, asToken)
 			if ok {
-				id, newCursor, ok := parseTokenKind(tokens, cursor, identifierKind)
+				id, newCursor, ok := parseIdentifier(tokens, cursor)
 				if !ok {
 					helpMessage(tokens, cursor, "Expected identifier after AS")
 					return nil, initialCursor, false
@@ -313,3 +824,302 @@ outer:
 
 	return &s, cursor, true
 }
+
+// parseFromItem parses the FROM clause, a list of tables joined with
+// commas or JOIN. Joins associate to the left.
+func parseFromItem(tokens []*Token, initialCursor uint, delimiters []Token) (*fromItem, uint, bool) {
+	cursor := initialCursor
+
+	commaToken := tokenFromSymbol(commaSymbol)
+	joinToken := tokenFromKeyword(JoinKeyword)
+	crossToken := tokenFromKeyword(CrossKeyword)
+
+	item, cursor, ok := parseTableItem(tokens, cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+
+	for {
+		join := joinExpression{a: *item}
+		conditional := false
+		if expectToken(tokens, cursor, commaToken) {
+			cursor++
+		} else if expectToken(tokens, cursor, crossToken) {
+			cursor++
+			if !expectToken(tokens, cursor, joinToken) {
+				helpMessage(tokens, cursor, "Expected JOIN after CROSS")
+				return nil, initialCursor, false
+			}
+			cursor++
+		} else if kind, newCursor, ok := parseJoinKind(tokens, cursor); ok {
+			join.kind = kind
+			conditional = true
+			cursor = newCursor
+		} else {
+			break
+		}
+
+		b, newCursor, ok := parseTableItem(tokens, cursor)
+		if !ok {
+			return nil, initialCursor, false
+		}
+		join.b = *b
+		cursor = newCursor
+
+		if conditional {
+			if expectToken(tokens, cursor, tokenFromKeyword(OnKeyword)) {
+				cursor++
+
+				onDelimiters := append([]Token{
+					commaToken,
+					joinToken,
+					crossToken,
+					tokenFromKeyword(InnerKeyword),
+					tokenFromKeyword(LeftKeyword),
+					tokenFromKeyword(RightKeyword),
+					tokenFromKeyword(FullKeyword),
+				}, delimiters...)
+				join.on, cursor, ok = parseExpression(tokens, cursor, onDelimiters, 0)
+				if !ok {
+					helpMessage(tokens, cursor, "Expected ON conditionals")
+					return nil, initialCursor, false
+				}
+			} else if expectToken(tokens, cursor, tokenFromKeyword(UsingKeyword)) {
+				cursor++
+
+				join.using, cursor, ok = parseColumnList(tokens, cursor)
+				if !ok {
+					return nil, initialCursor, false
+				}
+			} else {
+				helpMessage(tokens, cursor, "Expected ON or USING")
+				return nil, initialCursor, false
+			}
+		}
+
+		item = &fromItem{
+			join: &join,
+			kind: joinFromKind,
+		}
+	}
+
+	return item, cursor, true
+}
+
+// parseJoinKind parses [INNER] JOIN and {LEFT|RIGHT|FULL} [OUTER] JOIN
+func parseJoinKind(tokens []*Token, initialCursor uint) (joinKind, uint, bool) {
+	cursor := initialCursor
+
+	kind := innerJoin
+	outer := true
+	switch {
+	case expectToken(tokens, cursor, tokenFromKeyword(InnerKeyword)):
+		outer = false
+		cursor++
+	case expectToken(tokens, cursor, tokenFromKeyword(LeftKeyword)):
+		kind = leftJoin
+		cursor++
+	case expectToken(tokens, cursor, tokenFromKeyword(RightKeyword)):
+		kind = rightJoin
+		cursor++
+	case expectToken(tokens, cursor, tokenFromKeyword(FullKeyword)):
+		kind = fullJoin
+		cursor++
+	default:
+		outer = false
+	}
+
+	if outer && expectToken(tokens, cursor, tokenFromKeyword(OuterKeyword)) {
+		cursor++
+	}
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(JoinKeyword)) {
+		if cursor != initialCursor {
+			helpMessage(tokens, cursor, "Expected JOIN")
+		}
+
+		return 0, initialCursor, false
+	}
+	cursor++
+
+	return kind, cursor, true
+}
+
+// parseTableItem parses a table name or a subquery followed by an
+// optional alias
+func parseTableItem(tokens []*Token, initialCursor uint) (*fromItem, uint, bool) {
+	cursor := initialCursor
+
+	var item fromItem
+	if slct, newCursor, ok := parseSubquery(tokens, cursor); ok {
+		item = fromItem{
+			subquery: slct,
+			kind:     subqueryFromKind,
+		}
+		cursor = newCursor
+	} else if table, newCursor, ok := parseIdentifier(tokens, cursor); ok {
+		item = fromItem{
+			table: table,
+			kind:  tableFromKind,
+		}
+		cursor = newCursor
+	} else {
+		helpMessage(tokens, cursor, "Expected FROM item")
+		return nil, initialCursor, false
+	}
+
+	if _, newCursor, ok := parseToken(tokens, cursor, tokenFromKeyword(AsKeyword)); ok {
+		alias, newCursor, ok := parseIdentifier(tokens, newCursor)
+		if !ok {
+			helpMessage(tokens, newCursor, "Expected alias after AS")
+			return nil, initialCursor, false
+		}
+
+		item.alias, cursor = alias, newCursor
+	} else if isJoinStart(tokens, cursor) {
+		// LEFT, RIGHT, FULL, INNER and CROSS start the next join rather
+		// than naming this item
+	} else if alias, newCursor, ok := parseIdentifier(tokens, cursor); ok {
+		item.alias, cursor = alias, newCursor
+	}
+
+	return &item, cursor, true
+}
+
+// isJoinStart reports whether the tokens at the cursor are the keywords
+// of a JOIN
+func isJoinStart(tokens []*Token, cursor uint) bool {
+	switch {
+	case expectToken(tokens, cursor, tokenFromKeyword(CrossKeyword)),
+		expectToken(tokens, cursor, tokenFromKeyword(InnerKeyword)):
+		cursor++
+	case expectToken(tokens, cursor, tokenFromKeyword(LeftKeyword)),
+		expectToken(tokens, cursor, tokenFromKeyword(RightKeyword)),
+		expectToken(tokens, cursor, tokenFromKeyword(FullKeyword)):
+		cursor++
+		if expectToken(tokens, cursor, tokenFromKeyword(OuterKeyword)) {
+			cursor++
+		}
+	}
+
+	return expectToken(tokens, cursor, tokenFromKeyword(JoinKeyword))
+}
+
+// parseColumnList parses a parenthesized list of column names
+func parseColumnList(tokens []*Token, initialCursor uint) (*[]*Token, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(leftparenSymbol)) {
+		helpMessage(tokens, cursor, "Expected left paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	columns := []*Token{}
+	for {
+		if len(columns) > 0 {
+			if !expectToken(tokens, cursor, tokenFromSymbol(commaSymbol)) {
+				break
+			}
+			cursor++
+		}
+
+		column, newCursor, ok := parseIdentifier(tokens, cursor)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected column name")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		columns = append(columns, column)
+	}
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(rightparenSymbol)) {
+		helpMessage(tokens, cursor, "Expected right paren")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &columns, cursor, true
+}
+
+func parseGroupByItems(tokens []*Token, initialCursor uint, delimiters []Token) (*[]*expression, uint, bool) {
+	cursor := initialCursor
+
+	commaToken := tokenFromSymbol(commaSymbol)
+
+	exps := []*expression{}
+	for {
+		if len(exps) > 0 {
+			if !expectToken(tokens, cursor, commaToken) {
+				break
+			}
+			cursor++
+		}
+
+		exp, newCursor, ok := parseExpression(tokens, cursor, append([]Token{commaToken}, delimiters...), 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected GROUP BY expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		exps = append(exps, exp)
+	}
+
+	return &exps, cursor, true
+}
+
+func parseOrderByItems(tokens []*Token, initialCursor uint, delimiters []Token) (*[]*orderByItem, uint, bool) {
+	cursor := initialCursor
+
+	commaToken := tokenFromSymbol(commaSymbol)
+	ascToken := tokenFromKeyword(AscKeyword)
+	descToken := tokenFromKeyword(DescKeyword)
+	nullsToken := tokenFromKeyword(NullsKeyword)
+
+	items := []*orderByItem{}
+	for {
+		if len(items) > 0 {
+			if !expectToken(tokens, cursor, commaToken) {
+				break
+			}
+			cursor++
+		}
+
+		expDelimiters := append([]Token{commaToken, ascToken, descToken, nullsToken}, delimiters...)
+		exp, newCursor, ok := parseExpression(tokens, cursor, expDelimiters, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected ORDER BY expression")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		item := orderByItem{exp: exp}
+		if expectToken(tokens, cursor, descToken) {
+			item.desc = true
+			cursor++
+		} else if expectToken(tokens, cursor, ascToken) {
+			cursor++
+		}
+
+		// NULLs sort as if larger than any other value by default
+		item.nullsFirst = item.desc
+		if expectToken(tokens, cursor, nullsToken) {
+			cursor++
+			if expectToken(tokens, cursor, tokenFromKeyword(FirstKeyword)) {
+				item.nullsFirst = true
+			} else if expectToken(tokens, cursor, tokenFromKeyword(LastKeyword)) {
+				item.nullsFirst = false
+			} else {
+				helpMessage(tokens, cursor, "Expected FIRST or LAST after NULLS")
+				return nil, initialCursor, false
+			}
+			cursor++
+		}
+
+		items = append(items, &item)
+	}
+
+	return &items, cursor, true
+}
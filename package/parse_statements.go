@@ -11,7 +11,12 @@ func parseSelectStatement(tokens []*Token, initialCursor uint, delimiter Token)
 	slct := SelectStatement{}
 
 	fromToken := tokenFromKeyword(FromKeyword)
-	item, newCursor, ok := parseSelectItem(tokens, cursor, []Token{fromToken, delimiter})
+	groupToken := tokenFromKeyword(GroupKeyword)
+	havingToken := tokenFromKeyword(HavingKeyword)
+	orderToken := tokenFromKeyword(OrderKeyword)
+	limitToken := tokenFromKeyword(LimitKeyword)
+	offsetToken := tokenFromKeyword(OffsetKeyword)
+	item, newCursor, ok := parseSelectItem(tokens, cursor, []Token{fromToken, groupToken, havingToken, orderToken, limitToken, offsetToken, delimiter})
 	if !ok {
 		return nil, initialCursor, false
 	}
@@ -23,9 +28,8 @@ func parseSelectStatement(tokens []*Token, initialCursor uint, delimiter Token)
 
 	_, cursor, ok = parseToken(tokens, cursor, fromToken)
 	if ok {
-		from, newCursor, ok := parseTokenKind(tokens, cursor, identifierKind)
+		from, newCursor, ok := parseFromItem(tokens, cursor, []Token{whereToken, groupToken, havingToken, orderToken, limitToken, offsetToken, delimiter})
 		if !ok {
-			helpMessage(tokens, cursor, "Expected FROM item")
 			return nil, initialCursor, false
 		}
 
@@ -33,12 +37,9 @@ func parseSelectStatement(tokens []*Token, initialCursor uint, delimiter Token)
 		cursor = newCursor
 	}
 
-	limitToken := tokenFromKeyword(LimitKeyword)
-	offsetToken := tokenFromKeyword(OffsetKeyword)
-
 	_, cursor, ok = parseToken(tokens, cursor, whereToken)
 	if ok {
-		where, newCursor, ok := parseExpression(tokens, cursor, []Token{limitToken, offsetToken, delimiter}, 0)
+		where, newCursor, ok := parseExpression(tokens, cursor, []Token{groupToken, havingToken, orderToken, limitToken, offsetToken, delimiter}, 0)
 		if !ok {
 			helpMessage(tokens, cursor, "Expected WHERE conditionals")
 			return nil, initialCursor, false
@@ -48,6 +49,83 @@ func parseSelectStatement(tokens []*Token, initialCursor uint, delimiter Token)
 		cursor = newCursor
 	}
 
+	_, cursor, ok = parseToken(tokens, cursor, groupToken)
+	if ok {
+		_, cursor, ok = parseToken(tokens, cursor, tokenFromKeyword(ByKeyword))
+		if !ok {
+			helpMessage(tokens, cursor, "Expected BY after GROUP")
+			return nil, initialCursor, false
+		}
+
+		groupBy, newCursor, ok := parseGroupByItems(tokens, cursor, []Token{havingToken, orderToken, limitToken, offsetToken, delimiter})
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		slct.groupBy = groupBy
+		cursor = newCursor
+	}
+
+	_, cursor, ok = parseToken(tokens, cursor, havingToken)
+	if ok {
+		having, newCursor, ok := parseExpression(tokens, cursor, []Token{orderToken, limitToken, offsetToken, delimiter}, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected HAVING conditionals")
+			return nil, initialCursor, false
+		}
+
+		slct.having = having
+		cursor = newCursor
+	}
+
+	_, cursor, ok = parseToken(tokens, cursor, orderToken)
+	if ok {
+		_, cursor, ok = parseToken(tokens, cursor, tokenFromKeyword(ByKeyword))
+		if !ok {
+			helpMessage(tokens, cursor, "Expected BY after ORDER")
+			return nil, initialCursor, false
+		}
+
+		orderBy, newCursor, ok := parseOrderByItems(tokens, cursor, []Token{limitToken, offsetToken, delimiter})
+		if !ok {
+			return nil, initialCursor, false
+		}
+
+		slct.orderBy = orderBy
+		cursor = newCursor
+	}
+
+	// LIMIT and OFFSET may come in either order
+	for {
+		if slct.limit == nil && expectToken(tokens, cursor, limitToken) {
+			cursor++
+			limit, newCursor, ok := parseExpression(tokens, cursor, []Token{offsetToken, delimiter}, 0)
+			if !ok {
+				helpMessage(tokens, cursor, "Expected LIMIT value")
+				return nil, initialCursor, false
+			}
+
+			slct.limit = limit
+			cursor = newCursor
+			continue
+		}
+
+		if slct.offset == nil && expectToken(tokens, cursor, offsetToken) {
+			cursor++
+			offset, newCursor, ok := parseExpression(tokens, cursor, []Token{limitToken, delimiter}, 0)
+			if !ok {
+				helpMessage(tokens, cursor, "Expected OFFSET value")
+				return nil, initialCursor, false
+			}
+
+			slct.offset = offset
+			cursor = newCursor
+			continue
+		}
+
+		break
+	}
+
 	return &slct, cursor, true
 }
 
@@ -68,13 +146,42 @@ func parseInsertStatement(tokens []*Token, initialCursor uint, delimiter Token)
 	cursor++
 
 	// Look for table name
-	table, newCursor, ok := parseTokenKind(tokens, cursor, identifierKind)
+	table, newCursor, ok := parseIdentifier(tokens, cursor)
 	if !ok {
 		helpMessage(tokens, cursor, "Expected table name")
 		return nil, initialCursor, false
 	}
 	cursor = newCursor
 
+	// Look for an optional column list
+	var columns *[]*Token
+	if expectToken(tokens, cursor, tokenFromSymbol(leftparenSymbol)) {
+		cursor++
+
+		cols := []*Token{}
+		for !expectToken(tokens, cursor, tokenFromSymbol(rightparenSymbol)) {
+			if len(cols) > 0 {
+				if !expectToken(tokens, cursor, tokenFromSymbol(commaSymbol)) {
+					helpMessage(tokens, cursor, "Expected comma")
+					return nil, initialCursor, false
+				}
+				cursor++
+			}
+
+			col, newCursor, ok := parseIdentifier(tokens, cursor)
+			if !ok {
+				helpMessage(tokens, cursor, "Expected column name")
+				return nil, initialCursor, false
+			}
+			cursor = newCursor
+
+			cols = append(cols, col)
+		}
+		cursor++
+
+		columns = &cols
+	}
+
 	// Look for VALUES
 	if !expectToken(tokens, cursor, tokenFromKeyword(ValuesKeyword)) {
 		helpMessage(tokens, cursor, "Expected VALUES")
@@ -104,8 +211,9 @@ func parseInsertStatement(tokens []*Token, initialCursor uint, delimiter Token)
 	cursor++
 
 	return &InsertStatement{
-		table:  *table,
-		values: values,
+		table:   *table,
+		columns: columns,
+		values:  values,
 	}, cursor, true
 }
 
@@ -122,7 +230,13 @@ func parseCreateTableStatement(tokens []*Token, initialCursor uint, delimiter To
 	}
 	cursor++
 
-	name, newCursor, ok := parseTokenKind(tokens, cursor, identifierKind)
+	ifNotExists, newCursor, ok := parseIfNotExists(tokens, cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	name, newCursor, ok := parseIdentifier(tokens, cursor)
 	if !ok {
 		helpMessage(tokens, cursor, "Expected table name")
 		return nil, initialCursor, false
@@ -147,8 +261,272 @@ func parseCreateTableStatement(tokens []*Token, initialCursor uint, delimiter To
 	}
 	cursor++
 
+	// USING picks how the table is stored, as in USING columnar
+	var using *Token
+	if expectToken(tokens, cursor, tokenFromKeyword(UsingKeyword)) {
+		cursor++
+
+		using, newCursor, ok = parseIdentifier(tokens, cursor)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected access method")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+	}
+
 	return &CreateTableStatement{
-		name: *name,
-		cols: cols,
+		name:        *name,
+		ifNotExists: ifNotExists,
+		cols:        cols,
+		using:       using,
+	}, cursor, true
+}
+
+func parseCreateIndexStatement(tokens []*Token, initialCursor uint, delimiter Token) (*CreateIndexStatement, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(CreateKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	unique := false
+	if expectToken(tokens, cursor, tokenFromKeyword(UniqueKeyword)) {
+		unique = true
+		cursor++
+	}
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(IndexKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	name, newCursor, ok := parseIdentifier(tokens, cursor)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected index name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(OnKeyword)) {
+		helpMessage(tokens, cursor, "Expected ON")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	table, newCursor, ok := parseIdentifier(tokens, cursor)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected table name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(leftparenSymbol)) {
+		helpMessage(tokens, cursor, "Expected left parenthesis")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	column, newCursor, ok := parseIdentifier(tokens, cursor)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected column name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromSymbol(rightparenSymbol)) {
+		helpMessage(tokens, cursor, "Expected right parenthesis")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	return &CreateIndexStatement{
+		name:   *name,
+		unique: unique,
+		table:  *table,
+		column: *column,
+	}, cursor, true
+}
+
+func parseDropTableStatement(tokens []*Token, initialCursor uint, delimiter Token) (*DropTableStatement, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(DropKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(TableKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	ifExists, newCursor, ok := parseIfExists(tokens, cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	name, newCursor, ok := parseIdentifier(tokens, cursor)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected table name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	return &DropTableStatement{
+		name:     *name,
+		ifExists: ifExists,
+	}, cursor, true
+}
+
+func parseDropIndexStatement(tokens []*Token, initialCursor uint, delimiter Token) (*DropIndexStatement, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(DropKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(IndexKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	ifExists, newCursor, ok := parseIfExists(tokens, cursor)
+	if !ok {
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	name, newCursor, ok := parseIdentifier(tokens, cursor)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected index name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	return &DropIndexStatement{
+		name:     *name,
+		ifExists: ifExists,
 	}, cursor, true
 }
+
+func parseUpdateStatement(tokens []*Token, initialCursor uint, delimiter Token) (*UpdateStatement, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(UpdateKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	table, newCursor, ok := parseIdentifier(tokens, cursor)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected table name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(SetKeyword)) {
+		helpMessage(tokens, cursor, "Expected SET")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	whereToken := tokenFromKeyword(WhereKeyword)
+	commaToken := tokenFromSymbol(commaSymbol)
+
+	set := []*updateAssignment{}
+	for {
+		if len(set) > 0 {
+			if !expectToken(tokens, cursor, commaToken) {
+				break
+			}
+			cursor++
+		}
+
+		column, newCursor, ok := parseIdentifier(tokens, cursor)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected column name")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		if !expectToken(tokens, cursor, tokenFromSymbol(EqSymbol)) {
+			helpMessage(tokens, cursor, "Expected =")
+			return nil, initialCursor, false
+		}
+		cursor++
+
+		value, newCursor, ok := parseExpression(tokens, cursor, []Token{commaToken, whereToken, delimiter}, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected SET value")
+			return nil, initialCursor, false
+		}
+		cursor = newCursor
+
+		set = append(set, &updateAssignment{
+			column: *column,
+			value:  value,
+		})
+	}
+
+	updt := UpdateStatement{
+		table: *table,
+		set:   &set,
+	}
+
+	_, cursor, ok = parseToken(tokens, cursor, whereToken)
+	if ok {
+		where, newCursor, ok := parseExpression(tokens, cursor, []Token{delimiter}, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected WHERE conditionals")
+			return nil, initialCursor, false
+		}
+
+		updt.where = where
+		cursor = newCursor
+	}
+
+	return &updt, cursor, true
+}
+
+func parseDeleteStatement(tokens []*Token, initialCursor uint, delimiter Token) (*DeleteStatement, uint, bool) {
+	cursor := initialCursor
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(DeleteKeyword)) {
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	if !expectToken(tokens, cursor, tokenFromKeyword(FromKeyword)) {
+		helpMessage(tokens, cursor, "Expected FROM")
+		return nil, initialCursor, false
+	}
+	cursor++
+
+	table, newCursor, ok := parseIdentifier(tokens, cursor)
+	if !ok {
+		helpMessage(tokens, cursor, "Expected table name")
+		return nil, initialCursor, false
+	}
+	cursor = newCursor
+
+	dlt := DeleteStatement{
+		table: *table,
+	}
+
+	_, cursor, ok = parseToken(tokens, cursor, tokenFromKeyword(WhereKeyword))
+	if ok {
+		where, newCursor, ok := parseExpression(tokens, cursor, []Token{delimiter}, 0)
+		if !ok {
+			helpMessage(tokens, cursor, "Expected WHERE conditionals")
+			return nil, initialCursor, false
+		}
+
+		dlt.where = where
+		cursor = newCursor
+	}
+
+	return &dlt, cursor, true
+}
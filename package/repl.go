@@ -16,7 +16,7 @@ func doSelect(mb Backend, slct *SelectStatement) error {
 		return err
 	}
 
-	if len(results.Rows) == 0 {
+	if len(results.Columns) == 0 {
 		fmt.Println("(no results)")
 		return nil
 	}
@@ -35,9 +35,20 @@ func doSelect(mb Backend, slct *SelectStatement) error {
 		for i, cell := range result {
 			typ := results.Columns[i].Type
 			r := ""
+			if cell.IsNull() {
+				row = append(row, r)
+				continue
+			}
+
 			switch typ {
-			case IntType:
-				i := cell.AsInt()
+			case RealType, DoubleType:
+				r = formatFloat(cell.AsFloat64(), typ)
+			case NumericType:
+				r = cell.AsText()
+			case DateType, TimeType, TimestampType, TimestampTzType, IntervalType:
+				r = formatTemporal(cell, typ)
+			case SmallIntType, IntType, BigIntType:
+				i := cell.AsInt64()
 				if &i != nil {
 					r = fmt.Sprintf("%d", i)
 				}
@@ -75,6 +86,14 @@ func doSelect(mb Backend, slct *SelectStatement) error {
 	return nil
 }
 
+func printRowsAffected(n uint, verb string) {
+	if n == 1 {
+		fmt.Printf("(1 row %s)\n", verb)
+	} else {
+		fmt.Printf("(%d rows %s)\n", n, verb)
+	}
+}
+
 func RunRepl(b Backend) {
 	l, err := readline.NewEx(&readline.Config{
 		Prompt:          "# ",
@@ -120,17 +139,51 @@ repl:
 		for _, stmt := range ast.Statements {
 			switch stmt.Kind {
 			case CreateTableKind:
-				err = b.CreateTable(ast.Statements[0].CreateTableStatement)
+				err = b.CreateTable(stmt.CreateTableStatement)
 				if err != nil {
 					fmt.Println("Error creating table:", err)
 					continue repl
 				}
+			case CreateIndexKind:
+				err = b.CreateIndex(stmt.CreateIndexStatement)
+				if err != nil {
+					fmt.Println("Error creating index:", err)
+					continue repl
+				}
+			case DropTableKind:
+				err = b.DropTable(stmt.DropTableStatement)
+				if err != nil {
+					fmt.Println("Error dropping table:", err)
+					continue repl
+				}
+			case DropIndexKind:
+				err = b.DropIndex(stmt.DropIndexStatement)
+				if err != nil {
+					fmt.Println("Error dropping index:", err)
+					continue repl
+				}
 			case InsertKind:
 				err = b.Insert(stmt.InsertStatement)
 				if err != nil {
 					fmt.Println("Error inserting values:", err)
 					continue repl
 				}
+			case UpdateKind:
+				updated, err := b.Update(stmt.UpdateStatement)
+				if err != nil {
+					fmt.Println("Error updating values:", err)
+					continue repl
+				}
+
+				printRowsAffected(updated, "updated")
+			case DeleteKind:
+				deleted, err := b.Delete(stmt.DeleteStatement)
+				if err != nil {
+					fmt.Println("Error deleting values:", err)
+					continue repl
+				}
+
+				printRowsAffected(deleted, "deleted")
 			case SelectKind:
 				err := doSelect(b, stmt.SelectStatement)
 				if err != nil {